@@ -0,0 +1,326 @@
+// Package analytics summarizes JPush report data per campaign, platform,
+// vendor channel and day.
+//
+// Report records carry only a msg id, so every message is first registered
+// with the campaign (PushMessageInput.Type) and time it was sent. Records are
+// kept per message and replaced on re-ingestion, so polling the same report
+// repeatedly never double counts.
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sustring/push/jpush"
+)
+
+type Message struct {
+	MsgId    string
+	Campaign string
+	SentAt   time.Time
+}
+
+type GroupBy int
+
+const (
+	ByCampaign GroupBy = 1 << iota
+	ByPlatform
+	ByVendor
+	ByDay
+)
+
+type Key struct {
+	Campaign string `json:"campaign,omitempty"`
+	Platform string `json:"platform,omitempty"`
+	Vendor   string `json:"vendor,omitempty"`
+	Day      string `json:"day,omitempty"`
+}
+
+type Counters struct {
+	Messages  int `json:"messages"`
+	Target    int `json:"target"`
+	Sent      int `json:"sent"`
+	Received  int `json:"received"`
+	Displayed int `json:"displayed"`
+	Clicked   int `json:"clicked"`
+}
+
+type Row struct {
+	Key
+	Counters
+	DeliveryRate float64 `json:"delivery_rate"` // received / target, or / sent without a target
+	OpenRate     float64 `json:"open_rate"`     // clicked / received
+	VendorShare  float64 `json:"vendor_share"`  // share of the platform's received
+	// Time from send to receive, from delivery receipts, in seconds.
+	MedianTimeToReceive float64 `json:"median_time_to_receive"`
+	MeanTimeToReceive   float64 `json:"mean_time_to_receive"`
+}
+
+type record struct {
+	message  Message
+	received *jpush.ReceivedDetailResult
+	detail   *jpush.MessagesDetailResult
+	delays   map[jpush.Platform][]time.Duration
+}
+
+type Aggregator struct {
+	// Location decides which day a message belongs to, UTC when nil.
+	Location *time.Location
+
+	mu      sync.Mutex
+	records map[string]*record
+}
+
+func New() *Aggregator {
+	return &Aggregator{records: make(map[string]*record)}
+}
+
+func (a *Aggregator) Register(m Message) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if r, ok := a.records[m.MsgId]; ok {
+		r.message = m
+		return
+	}
+	a.records[m.MsgId] = &record{message: m, delays: make(map[jpush.Platform][]time.Duration)}
+}
+
+func (a *Aggregator) get(msgId string) (*record, error) {
+	r, ok := a.records[msgId]
+	if !ok {
+		return nil, fmt.Errorf("analytics: message %s not registered", msgId)
+	}
+	return r, nil
+}
+
+func (a *Aggregator) AddReceivedDetail(d *jpush.ReceivedDetailResult) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.get(string(d.MsgId))
+	if err != nil {
+		return err
+	}
+	r.received = d
+	return nil
+}
+
+// AddMessageDetail records a messages detail report; it takes precedence
+// over the received detail of the same message, as it has targets, displays
+// and clicks.
+func (a *Aggregator) AddMessageDetail(d *jpush.MessagesDetailResult) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.get(string(d.MsgId))
+	if err != nil {
+		return err
+	}
+	r.detail = d
+	return nil
+}
+
+// AddReceipt records when one device received the message, e.g. from a
+// jpush.CallbackReceived event.
+func (a *Aggregator) AddReceipt(msgId string, platform jpush.Platform, receivedAt time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.get(msgId)
+	if err != nil {
+		return err
+	}
+	if delay := receivedAt.Sub(r.message.SentAt); delay >= 0 {
+		r.delays[platform] = append(r.delays[platform], delay)
+	}
+	return nil
+}
+
+type fact struct {
+	platform jpush.Platform
+	vendor   string
+	Counters
+}
+
+func (r *record) facts() []fact {
+	if r.detail != nil && r.detail.Details != nil {
+		var list []fact
+		for _, stats := range []*jpush.PlatformMessageStats{r.detail.Details.Notification, r.detail.Details.Message} {
+			if stats != nil {
+				list = append(list, platformFacts(stats)...)
+			}
+		}
+		return list
+	}
+	d := r.received
+	if d == nil {
+		return nil
+	}
+	list := []fact{
+		{platform: jpush.PlatformAndroid, vendor: "jpush", Counters: Counters{Sent: d.JPushReceived, Received: d.JPushReceived}},
+		{platform: jpush.PlatformAndroid, vendor: "pns", Counters: Counters{Sent: d.AndroidPNSSent}},
+		{platform: jpush.PlatformIOS, vendor: "apns", Counters: Counters{Sent: d.IOSAPNSSent, Received: d.IOSAPNSReceived}},
+		{platform: jpush.PlatformQuickApp, vendor: "jpush", Counters: Counters{Sent: d.QuickappJpushReceived, Received: d.QuickappJpushReceived}},
+		{platform: jpush.PlatformQuickApp, vendor: "pns", Counters: Counters{Sent: d.QuickappPNSSent}},
+	}
+	out := list[:0]
+	for _, f := range list {
+		if f.Sent != 0 || f.Received != 0 {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func platformFacts(s *jpush.PlatformMessageStats) []fact {
+	var list []fact
+	if s.Android != nil {
+		if len(s.Android.Vendors) > 0 {
+			for vendor, v := range s.Android.Vendors {
+				if v != nil {
+					list = append(list, fact{platform: jpush.PlatformAndroid, vendor: vendor, Counters: statsCounters(v)})
+				}
+			}
+		} else {
+			list = append(list, fact{platform: jpush.PlatformAndroid, Counters: statsCounters(&s.Android.MessageStats)})
+		}
+	}
+	if s.IOS != nil {
+		list = append(list, fact{platform: jpush.PlatformIOS, vendor: "apns", Counters: Counters{
+			Target:    s.IOS.ApnsTarget,
+			Sent:      s.IOS.ApnsSent,
+			Received:  s.IOS.ApnsReceived,
+			Displayed: s.IOS.ApnsDisplay,
+			Clicked:   s.IOS.ApnsClick,
+		}})
+	}
+	if s.QuickApp != nil {
+		list = append(list, fact{platform: jpush.PlatformQuickApp, Counters: statsCounters(s.QuickApp)})
+	}
+	if s.Hmos != nil {
+		list = append(list, fact{platform: jpush.PlatformHmos, Counters: statsCounters(s.Hmos)})
+	}
+	return list
+}
+
+func statsCounters(s *jpush.MessageStats) Counters {
+	return Counters{
+		Target:    s.Target,
+		Sent:      s.OnlinePush,
+		Received:  s.Received,
+		Displayed: s.Display,
+		Clicked:   s.Click + s.MsgClick,
+	}
+}
+
+// Rows aggregates every registered message along the chosen dimensions;
+// dimensions left out are blank in the returned keys.
+func (a *Aggregator) Rows(by GroupBy) []*Row {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	loc := a.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	rows := make(map[Key]*Row)
+	delays := make(map[Key][]time.Duration)
+	messages := make(map[Key]map[string]bool)
+	for msgId, r := range a.records {
+		base := Key{}
+		if by&ByCampaign != 0 {
+			base.Campaign = r.message.Campaign
+		}
+		if by&ByDay != 0 && !r.message.SentAt.IsZero() {
+			base.Day = r.message.SentAt.In(loc).Format("2006-01-02")
+		}
+		for _, f := range r.facts() {
+			key := base
+			if by&ByPlatform != 0 {
+				key.Platform = string(f.platform)
+			}
+			if by&ByVendor != 0 {
+				key.Vendor = f.vendor
+			}
+			row := rows[key]
+			if row == nil {
+				row = &Row{Key: key}
+				rows[key] = row
+				messages[key] = make(map[string]bool)
+			}
+			row.Target += f.Target
+			row.Sent += f.Sent
+			row.Received += f.Received
+			row.Displayed += f.Displayed
+			row.Clicked += f.Clicked
+			messages[key][msgId] = true
+		}
+		// receipts are not split by vendor, so they count towards every
+		// vendor row of their platform
+		for platform, list := range r.delays {
+			for key := range rows {
+				if key.Campaign == base.Campaign && key.Day == base.Day &&
+					(by&ByPlatform == 0 || key.Platform == string(platform)) && messages[key][msgId] {
+					delays[key] = append(delays[key], list...)
+				}
+			}
+		}
+	}
+
+	platformReceived := make(map[Key]int)
+	for key, row := range rows {
+		key.Vendor = ""
+		platformReceived[key] += row.Received
+	}
+	list := make([]*Row, 0, len(rows))
+	for key, row := range rows {
+		row.Messages = len(messages[key])
+		row.DeliveryRate = ratio(row.Received, row.Target)
+		if row.Target == 0 {
+			row.DeliveryRate = ratio(row.Received, row.Sent)
+		}
+		row.OpenRate = ratio(row.Clicked, row.Received)
+		platformKey := key
+		platformKey.Vendor = ""
+		row.VendorShare = ratio(row.Received, platformReceived[platformKey])
+		row.MedianTimeToReceive, row.MeanTimeToReceive = durationStats(delays[key])
+		list = append(list, row)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].Key, list[j].Key
+		if a.Campaign != b.Campaign {
+			return a.Campaign < b.Campaign
+		}
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Platform != b.Platform {
+			return a.Platform < b.Platform
+		}
+		return a.Vendor < b.Vendor
+	})
+	return list
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func durationStats(list []time.Duration) (median, mean float64) {
+	if len(list) == 0 {
+		return 0, 0
+	}
+	sorted := append([]time.Duration(nil), list...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	mid := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		mid = (sorted[len(sorted)/2-1] + mid) / 2
+	}
+	return mid.Seconds(), (sum / time.Duration(len(sorted))).Seconds()
+}
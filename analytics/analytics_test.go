@@ -0,0 +1,73 @@
+package analytics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sustring/push/jpush"
+)
+
+func TestAggregatorRows(t *testing.T) {
+	sent := time.Date(2020, 6, 10, 8, 0, 0, 0, time.UTC)
+	a := New()
+	a.Register(Message{MsgId: "1", Campaign: "order", SentAt: sent})
+	a.Register(Message{MsgId: "2", Campaign: "promo", SentAt: sent})
+
+	err := a.AddMessageDetail(&jpush.MessagesDetailResult{
+		MsgId: "1",
+		Details: &jpush.MessageDetail{Notification: &jpush.PlatformMessageStats{
+			Android: &jpush.AndroidMessageStats{Vendors: map[string]*jpush.MessageStats{
+				"xiaomi": {Target: 10, OnlinePush: 10, Received: 9, Click: 3},
+				"huawei": {Target: 10, OnlinePush: 10, Received: 3, Click: 0},
+			}},
+			IOS: &jpush.IOSMessageStats{ApnsTarget: 4, ApnsSent: 4, ApnsReceived: 4, ApnsClick: 2},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddReceivedDetail(&jpush.ReceivedDetailResult{MsgId: "2", JPushReceived: 5, IOSAPNSSent: 2, IOSAPNSReceived: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddReceivedDetail(&jpush.ReceivedDetailResult{MsgId: "3"}); err == nil {
+		t.Fatal("expected unregistered message to be rejected")
+	}
+	a.AddReceipt("1", jpush.PlatformIOS, sent.Add(2*time.Second))
+	a.AddReceipt("1", jpush.PlatformIOS, sent.Add(4*time.Second))
+
+	rows := a.Rows(ByCampaign | ByPlatform | ByVendor)
+	var xiaomi, apns *Row
+	for _, r := range rows {
+		switch {
+		case r.Campaign == "order" && r.Vendor == "xiaomi":
+			xiaomi = r
+		case r.Campaign == "order" && r.Vendor == "apns":
+			apns = r
+		}
+	}
+	if xiaomi == nil || xiaomi.DeliveryRate != 0.9 || xiaomi.VendorShare != 0.75 || xiaomi.OpenRate != 3.0/9 {
+		t.Fatalf("unexpected xiaomi row %+v", xiaomi)
+	}
+	if apns == nil || apns.MedianTimeToReceive != 3 || apns.OpenRate != 0.5 {
+		t.Fatalf("unexpected apns row %+v", apns)
+	}
+
+	rows = a.Rows(ByCampaign | ByDay)
+	if len(rows) != 2 || rows[0].Campaign != "order" || rows[0].Received != 16 || rows[0].Day != "2020-06-10" {
+		t.Fatalf("unexpected campaign rows %+v", rows)
+	}
+	if rows[1].Received != 6 || rows[1].Messages != 1 {
+		t.Fatalf("unexpected promo row %+v", rows[1])
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "order,,,2020-06-10,1,24,24,16,") {
+		t.Fatalf("unexpected csv %q", buf.String())
+	}
+}
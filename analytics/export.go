@@ -0,0 +1,46 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+var csvHeader = []string{
+	"campaign", "platform", "vendor", "day",
+	"messages", "target", "sent", "received", "displayed", "clicked",
+	"delivery_rate", "open_rate", "vendor_share",
+	"median_time_to_receive", "mean_time_to_receive",
+}
+
+func WriteCSV(w io.Writer, rows []*Row) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		err := cw.Write([]string{
+			r.Campaign, r.Platform, r.Vendor, r.Day,
+			strconv.Itoa(r.Messages), strconv.Itoa(r.Target), strconv.Itoa(r.Sent),
+			strconv.Itoa(r.Received), strconv.Itoa(r.Displayed), strconv.Itoa(r.Clicked),
+			formatFloat(r.DeliveryRate), formatFloat(r.OpenRate), formatFloat(r.VendorShare),
+			formatFloat(r.MedianTimeToReceive), formatFloat(r.MeanTimeToReceive),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func WriteJSON(w io.Writer, rows []*Row) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
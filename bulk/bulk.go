@@ -0,0 +1,257 @@
+// Package bulk runs large batches of JPush device operations, such as moving
+// users between tags or clearing the aliases of deleted accounts, each of
+// which is a separate HTTP request.
+//
+// Operations run with bounded concurrency under a rate limiter. Failures are
+// collected per operation instead of stopping the run, and a checkpoint file
+// records finished operations so a crashed run can be resumed.
+package bulk
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sustring/push/jpush"
+)
+
+// Devices is the part of jpush.DeviceClient the runner calls.
+type Devices interface {
+	DeviceSet(registrationId string, payload *jpush.DeviceSettingPayload) error
+	AliasDelete(alias string) error
+	AliasUnbind(alias string, registrationIds []string) error
+	TagUpdate(tag string, payload *jpush.TagUpdatePayload) error
+}
+
+type Kind int
+
+const (
+	KindDeviceSet Kind = iota + 1
+	KindAliasDelete
+	KindAliasUnbind
+	KindTagUpdate
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindDeviceSet:
+		return "device_set"
+	case KindAliasDelete:
+		return "alias_delete"
+	case KindAliasUnbind:
+		return "alias_unbind"
+	case KindTagUpdate:
+		return "tag_update"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Operation is one device API call. Id must be unique, stable across runs
+// and free of newlines, as it is what the checkpoint records.
+type Operation struct {
+	Id              string
+	Kind            Kind
+	RegistrationId  string
+	Device          *jpush.DeviceSettingPayload
+	Alias           string
+	RegistrationIds []string // devices to unbind from Alias
+	Tag             string
+	Tags            *jpush.TagUpdatePayload
+}
+
+func DeviceSet(id, registrationId string, payload *jpush.DeviceSettingPayload) *Operation {
+	return &Operation{Id: id, Kind: KindDeviceSet, RegistrationId: registrationId, Device: payload}
+}
+
+func AliasDelete(id, alias string) *Operation {
+	return &Operation{Id: id, Kind: KindAliasDelete, Alias: alias}
+}
+
+func AliasUnbind(id, alias string, registrationIds []string) *Operation {
+	return &Operation{Id: id, Kind: KindAliasUnbind, Alias: alias, RegistrationIds: registrationIds}
+}
+
+func TagUpdate(id, tag string, payload *jpush.TagUpdatePayload) *Operation {
+	return &Operation{Id: id, Kind: KindTagUpdate, Tag: tag, Tags: payload}
+}
+
+func (o *Operation) run(d Devices) error {
+	switch o.Kind {
+	case KindDeviceSet:
+		return d.DeviceSet(o.RegistrationId, o.Device)
+	case KindAliasDelete:
+		return d.AliasDelete(o.Alias)
+	case KindAliasUnbind:
+		return d.AliasUnbind(o.Alias, o.RegistrationIds)
+	case KindTagUpdate:
+		return d.TagUpdate(o.Tag, o.Tags)
+	}
+	return fmt.Errorf("bulk: unknown operation kind %v", o.Kind)
+}
+
+// Failure is an operation that failed after all retries. APIError is set
+// when JPush answered with an error.
+type Failure struct {
+	Operation *Operation
+	Err       error
+	APIError  *jpush.APIError
+}
+
+type Progress struct {
+	Succeeded int
+	Failed    int
+	Skipped   int // already done according to the checkpoint
+}
+
+type Result struct {
+	Progress
+	Failures []*Failure
+	Stopped  bool // stop was closed before every operation ran
+}
+
+type Config struct {
+	Concurrency int           // parallel requests, default 4
+	Retries     int           // retries of temporary API errors, default 3
+	RetryWait   time.Duration // wait when JPush gives no reset time, default 1 second
+	// Limiter, when set, is waited on before every request.
+	Limiter Limiter
+	// Checkpoint is the path of the file recording finished operations;
+	// empty disables checkpointing.
+	Checkpoint string
+	// OnProgress is called after every operation, never concurrently.
+	OnProgress func(Progress)
+}
+
+func (c *Config) setDefaults() {
+	if c.Concurrency <= 0 {
+		c.Concurrency = 4
+	}
+	if c.Retries < 0 {
+		c.Retries = 0
+	} else if c.Retries == 0 {
+		c.Retries = 3
+	}
+	if c.RetryWait <= 0 {
+		c.RetryWait = time.Second
+	}
+}
+
+type Runner struct {
+	devices Devices
+	config  Config
+	sleep   func(time.Duration)
+}
+
+// New returns a runner; set Config.Retries to a negative value to disable
+// retries.
+func New(devices Devices, config Config) *Runner {
+	config.setDefaults()
+	return &Runner{devices: devices, config: config, sleep: time.Sleep}
+}
+
+// Run executes operations from ops until it is closed or stop is closed.
+// Operations recorded in the checkpoint are skipped; failed ones are not
+// recorded, so running again retries them. The returned error is only about
+// the checkpoint file.
+func (r *Runner) Run(ops <-chan *Operation, stop <-chan struct{}) (*Result, error) {
+	var cp *checkpoint
+	if r.config.Checkpoint != "" {
+		var err error
+		cp, err = openCheckpoint(r.config.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		defer cp.Close()
+	}
+
+	result := &Result{}
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	report := func(f func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		f()
+		if r.config.OnProgress != nil {
+			r.config.OnProgress(result.Progress)
+		}
+	}
+
+	work := make(chan *Operation)
+	for i := 0; i < r.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for op := range work {
+				err := r.run(op)
+				if err == nil && cp != nil {
+					if cerr := cp.Done(op.Id); cerr != nil {
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = cerr
+						}
+						mu.Unlock()
+					}
+				}
+				report(func() {
+					if err == nil {
+						result.Succeeded++
+						return
+					}
+					f := &Failure{Operation: op, Err: err}
+					errors.As(err, &f.APIError)
+					result.Failed++
+					result.Failures = append(result.Failures, f)
+				})
+			}
+		}()
+	}
+
+loop:
+	for {
+		select {
+		case <-stop:
+			result.Stopped = true
+			break loop
+		case op, ok := <-ops:
+			if !ok {
+				break loop
+			}
+			if cp != nil && cp.Has(op.Id) {
+				report(func() { result.Skipped++ })
+				continue
+			}
+			select {
+			case work <- op:
+			case <-stop:
+				result.Stopped = true
+				break loop
+			}
+		}
+	}
+	close(work)
+	wg.Wait()
+	return result, firstErr
+}
+
+// run calls the API, retrying temporary errors.
+func (r *Runner) run(op *Operation) error {
+	for attempt := 0; ; attempt++ {
+		if r.config.Limiter != nil {
+			r.config.Limiter.Wait()
+		}
+		err := op.run(r.devices)
+		var apiErr *jpush.APIError
+		if err == nil || !errors.As(err, &apiErr) || !apiErr.Temporary() || attempt >= r.config.Retries {
+			return err
+		}
+		wait := apiErr.RetryAfter
+		if wait <= 0 {
+			wait = r.config.RetryWait
+		}
+		r.sleep(wait)
+	}
+}
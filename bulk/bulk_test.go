@@ -0,0 +1,173 @@
+package bulk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sustring/push/jpush"
+)
+
+type fakeDevices struct {
+	mu       sync.Mutex
+	calls    map[string]int
+	failures map[string][]error // errors returned by successive calls
+}
+
+func (f *fakeDevices) call(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[key]++
+	if list := f.failures[key]; len(list) > 0 {
+		f.failures[key] = list[1:]
+		return list[0]
+	}
+	return nil
+}
+
+func (f *fakeDevices) DeviceSet(registrationId string, payload *jpush.DeviceSettingPayload) error {
+	return f.call("set:" + registrationId)
+}
+
+func (f *fakeDevices) AliasDelete(alias string) error {
+	return f.call("alias:" + alias)
+}
+
+func (f *fakeDevices) AliasUnbind(alias string, registrationIds []string) error {
+	return f.call("unbind:" + alias)
+}
+
+func (f *fakeDevices) TagUpdate(tag string, payload *jpush.TagUpdatePayload) error {
+	return f.call("tag:" + tag)
+}
+
+func feed(ops ...*Operation) <-chan *Operation {
+	ch := make(chan *Operation, len(ops))
+	for _, op := range ops {
+		ch <- op
+	}
+	close(ch)
+	return ch
+}
+
+func TestRunnerFailuresAndResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bulk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	notFound := &jpush.APIError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request", Code: 7002}
+	limited := &jpush.APIError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests", RetryAfter: 3 * time.Second}
+	devices := &fakeDevices{calls: make(map[string]int), failures: map[string][]error{
+		"set:r2":   {notFound},
+		"alias:u1": {limited},
+		"tag:vip":  {fmt.Errorf("connection reset")},
+	}}
+	var ops []*Operation
+	for i := 1; i <= 3; i++ {
+		ops = append(ops, DeviceSet(fmt.Sprintf("set-%d", i), fmt.Sprintf("r%d", i), &jpush.DeviceSettingPayload{}))
+	}
+	ops = append(ops, AliasDelete("alias-u1", "u1"), TagUpdate("tag-vip", "vip", &jpush.TagUpdatePayload{Add: []string{"r1"}}))
+
+	var progress []Progress
+	runner := New(devices, Config{Concurrency: 2, Checkpoint: path, OnProgress: func(p Progress) { progress = append(progress, p) }})
+	var slept []time.Duration
+	runner.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	result, err := runner.Run(feed(ops...), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Succeeded != 3 || result.Failed != 2 || len(progress) != 5 || progress[4] != result.Progress {
+		t.Fatalf("unexpected result %+v, progress %v", result, progress)
+	}
+	if len(slept) != 1 || slept[0] != 3*time.Second || devices.calls["alias:u1"] != 2 {
+		t.Fatalf("rate limited call not retried after reset: %v", slept)
+	}
+	sort.Slice(result.Failures, func(i, j int) bool { return result.Failures[i].Operation.Id < result.Failures[j].Operation.Id })
+	if f := result.Failures[0]; f.Operation.Id != "set-2" || f.APIError != notFound {
+		t.Fatalf("unexpected failure %+v", f)
+	}
+	if f := result.Failures[1]; f.Operation.Id != "tag-vip" || f.APIError != nil {
+		t.Fatalf("unexpected failure %+v", f)
+	}
+
+	// a resumed run only retries what failed
+	result, err = New(devices, Config{Checkpoint: path}).Run(feed(ops...), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Skipped != 3 || result.Succeeded != 2 || result.Failed != 0 {
+		t.Fatalf("unexpected resumed result %+v", result)
+	}
+	if devices.calls["set:r1"] != 1 || devices.calls["set:r2"] != 2 {
+		t.Fatalf("unexpected calls %v", devices.calls)
+	}
+}
+
+func TestCheckpointTruncatedLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bulk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+	if err := ioutil.WriteFile(path, []byte("a\nb\nc-cut"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := openCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Has("a") || !cp.Has("b") || cp.Has("c-cut") {
+		t.Fatalf("unexpected ids %v", cp.done)
+	}
+	if err := cp.Done("c"); err != nil {
+		t.Fatal(err)
+	}
+	cp.Close()
+
+	cp, err = openCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	if !cp.Has("c") || cp.Has("c-cut") {
+		t.Fatalf("unexpected ids %v", cp.done)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var waited time.Duration
+	for _, rate := range []float64{0, -1, math.NaN()} {
+		if _, err := NewLimiter(rate, 1); err == nil {
+			t.Errorf("NewLimiter accepted rate %v", rate)
+		}
+	}
+	limiter, err := NewLimiter(10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := limiter.(*rateLimiter)
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) { waited += d }
+
+	for i := 0; i < 4; i++ {
+		l.Wait()
+	}
+	// two go out at once, the next two 100ms apart
+	if waited != 100*time.Millisecond+200*time.Millisecond {
+		t.Fatalf("waited %v", waited)
+	}
+}
@@ -0,0 +1,64 @@
+package bulk
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// checkpoint is an append-only file of finished operation ids, one per
+// line.
+type checkpoint struct {
+	mu   sync.Mutex
+	file *os.File
+	done map[string]bool
+}
+
+func openCheckpoint(path string) (*checkpoint, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	cp := &checkpoint{file: file, done: make(map[string]bool)}
+	lines := strings.Split(string(data), "\n")
+	// the last element is empty unless a crash cut the line short; that id
+	// is cut off the file so the next id starts on its own line
+	if last := lines[len(lines)-1]; last != "" {
+		if err := file.Truncate(int64(len(data) - len(last))); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	for _, id := range lines[:len(lines)-1] {
+		if id != "" {
+			cp.done[id] = true
+		}
+	}
+	return cp, nil
+}
+
+func (c *checkpoint) Has(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done[id]
+}
+
+func (c *checkpoint) Done(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.file.WriteString(id + "\n"); err != nil {
+		return err
+	}
+	c.done[id] = true
+	return c.file.Sync()
+}
+
+func (c *checkpoint) Close() error {
+	return c.file.Close()
+}
@@ -0,0 +1,57 @@
+package bulk
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limiter blocks until the next request may be sent.
+type Limiter interface {
+	Wait()
+}
+
+// rateLimiter spaces requests evenly, allowing bursts of up to burst
+// requests after an idle period.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	next     time.Time
+	now      func() time.Time
+	sleep    func(time.Duration)
+}
+
+// NewLimiter allows perSecond requests per second on average. JPush limits
+// calls per app and minute, so a limit of n per minute is NewLimiter(n/60.0, 1).
+// The rate must be positive.
+func NewLimiter(perSecond float64, burst int) (Limiter, error) {
+	if !(perSecond > 0) {
+		return nil, fmt.Errorf("bulk: rate %v is not positive", perSecond)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		burst:    burst,
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}, nil
+}
+
+func (l *rateLimiter) Wait() {
+	l.mu.Lock()
+	now := l.now()
+	// an idle limiter has saved up at most burst requests
+	if earliest := now.Add(-time.Duration(l.burst-1) * l.interval); l.next.Before(earliest) {
+		l.next = earliest
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait := at.Sub(now); wait > 0 {
+		l.sleep(wait)
+	}
+}
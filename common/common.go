@@ -1,5 +1,7 @@
 package common
 
+import "time"
+
 type GetDeviceInput struct {
 	Id string
 }
@@ -8,19 +10,48 @@ type GetDeviceOutput struct {
 	Id      string
 	Alias   string
 	TagList []string
+	Mobile  string
 }
 
+// SetDeviceInput changes only what is given: an empty Alias or Mobile
+// leaves it unchanged, and the Clean flags clear it instead.
 type SetDeviceInput struct {
-	Id        string
-	Alias     string
-	CleanTags bool
-	AddTags   []string
-	DelTags   []string
+	Id          string
+	Alias       string
+	CleanAlias  bool
+	Mobile      string
+	CleanMobile bool
+	CleanTags   bool
+	AddTags     []string
+	DelTags     []string
 }
 
 type SetDeviceOutput struct {
 }
 
+// BindMobileInput binds a mobile number to a device, for SMS supplements to
+// fall back to; an empty Mobile unbinds the current one.
+type BindMobileInput struct {
+	Id     string
+	Mobile string
+}
+
+type BindMobileOutput struct {
+}
+
+type GetDeviceStatusInput struct {
+	Ids []string
+}
+
+type DeviceStatus struct {
+	Online     bool
+	LastOnline time.Time // zero when online or unknown
+}
+
+type GetDeviceStatusOutput struct {
+	Devices map[string]*DeviceStatus // by Id, missing for unknown devices
+}
+
 type UpdateTagInput struct {
 	Tag     string
 	AddList []string
@@ -46,12 +77,38 @@ type CheckTagOutput struct {
 	Result bool
 }
 
+// PlatformType is a bitmask of target platforms; ALL targets every platform.
 type PlatformType int
 
+const ALL PlatformType = 0
+
 const (
-	ALL PlatformType = iota
-	Android
+	Android PlatformType = 1 << iota
 	IOS
+	QuickApp
+	Hmos
+
+	platformMask = Android | IOS | QuickApp | Hmos
+)
+
+func (t PlatformType) Has(p PlatformType) bool {
+	return t == ALL || t&p != 0
+}
+
+func (t PlatformType) Valid() bool {
+	return t&^platformMask == 0
+}
+
+// NotificationCategory classifies a message so vendor channels that throttle
+// marketing traffic can keep service messages in their high-priority lanes.
+type NotificationCategory int
+
+const (
+	CategoryDefault NotificationCategory = iota
+	CategoryTransactional
+	CategorySocial
+	CategoryMarketing
+	CategorySystem
 )
 
 type AudienceInfo struct {
@@ -59,6 +116,47 @@ type AudienceInfo struct {
 	TagList   []string
 }
 
+// RichContent is the expanded presentation of a notification. Picture wins
+// over InboxLines, which wins over BigText, on platforms showing only one.
+type RichContent struct {
+	Title      string
+	BigText    string
+	InboxLines []string
+	Picture    string // image url
+	DeepLink   string // uri opened on click
+}
+
+// InAppMessage is a custom message delivered to the app without being shown
+// by the system. It can travel with a notification in the same push.
+type InAppMessage struct {
+	Title       string
+	Content     string
+	ContentType string
+	Extras      map[string]interface{}
+}
+
+// SmsFallback sends a templated SMS to the device's bound mobile number when
+// the push has not been received after DelaySeconds.
+type SmsFallback struct {
+	TemplateId     int64
+	TemplateParams map[string]interface{}
+	DelaySeconds   int
+	ActiveFilter   *bool // nil keeps the provider default of skipping active users
+}
+
+// QuickAppContent completes a notification for quick apps. An empty Title
+// falls back to the rich content's.
+type QuickAppContent struct {
+	Title string
+	Page  string // page opened on click, e.g. /page1
+}
+
+// LocalizedContent is the copy sent to users of one locale.
+type LocalizedContent struct {
+	Title string
+	Alert string
+}
+
 type PushMessageInput struct {
 	Platform PlatformType
 	Id       int64
@@ -68,10 +166,29 @@ type PushMessageInput struct {
 	Audience     AudienceInfo
 	Presentation bool
 	//Extra        map[string]interface{}
+	TruncateAlert bool   // shorten Alert to fit the platform size limits
+	HmosCategory  string // HarmonyOS notification category, e.g. IM, EXPRESS; defaults to Category's
+	Category      NotificationCategory
+	Rich          *RichContent
+	InApp         *InAppMessage // sent alongside the notification when set
+	Sms           *SmsFallback
+	QuickApp      *QuickAppContent // required when Platform has QuickApp
+
+	// Locales maps a locale such as "zh-CN" to its copy. When set, one push
+	// is sent per locale, narrowed to that locale's audience tag, and one
+	// with Alert, unless empty, to the devices without any of those tags.
+	Locales map[string]LocalizedContent
+	// AlertLocKey and AlertLocArgs let an iOS-only push be localized on the
+	// device from the app's strings file instead of fanning out; Locales are
+	// then ignored. Other platforms cannot use them.
+	AlertLocKey  string
+	AlertLocArgs []string
 }
 
 type PushMessageOutput struct {
-	MsgId string
+	MsgId     string
+	MsgIds    []string // every push sent, when the message was split
+	Truncated bool
 }
 
 type InspectMessageInput struct {
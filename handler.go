@@ -7,6 +7,7 @@ import (
 
 type API interface {
 	SetDevice(in *common.SetDeviceInput) (*common.SetDeviceOutput, error)
+	BindMobile(in *common.BindMobileInput) (*common.BindMobileOutput, error)
 	GetDevice(in *common.GetDeviceInput) (*common.GetDeviceOutput, error)
 	UpdateTag(in *common.UpdateTagInput) (*common.UpdateTagOutput, error)
 	DeleteTag(in *common.DeleteTagInput) (*common.DeleteTagOutput, error)
@@ -15,6 +16,15 @@ type API interface {
 	InspectMessage(in *common.InspectMessageInput) (*common.InspectMessageOutput, error)
 }
 
+// DeviceStatusAPI is implemented by providers that can tell whether a
+// device is online, e.g. to choose between a data message and a
+// notification.
+type DeviceStatusAPI interface {
+	GetDeviceStatus(in *common.GetDeviceStatusInput) (*common.GetDeviceStatusOutput, error)
+}
+
+var _ DeviceStatusAPI = (*jpush.Client)(nil)
+
 func NewJPushClient(appKey, masterSecret string) API {
 	return jpush.NewClient(appKey, masterSecret, "", "")
 }
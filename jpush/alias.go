@@ -0,0 +1,160 @@
+package jpush
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxAliasDevices is how many devices JPush binds to one alias.
+const MaxAliasDevices = 10
+
+// AliasPolicy limits the devices bound to one alias to MaxDevices, from 1
+// to JPush's own MaxAliasDevices.
+type AliasPolicy struct {
+	MaxDevices int
+}
+
+var (
+	AliasUnlimited    = AliasPolicy{MaxDevices: MaxAliasDevices}
+	AliasSingleDevice = AliasPolicy{MaxDevices: 1}
+)
+
+// AliasKeepNewest keeps the n most recently bound or seen devices.
+func AliasKeepNewest(n int) AliasPolicy {
+	return AliasPolicy{MaxDevices: n}
+}
+
+func (p AliasPolicy) validate() error {
+	if p.MaxDevices <= 0 || p.MaxDevices > MaxAliasDevices {
+		return ValidationError{Field: "max_devices", Message: fmt.Sprintf("%d is out of range 1-%d", p.MaxDevices, MaxAliasDevices)}
+	}
+	return nil
+}
+
+// AliasDisplacement records devices unbound to make room for another.
+type AliasDisplacement struct {
+	Alias          string
+	RegistrationId string // the device being bound
+	Displaced      []string
+	Policy         AliasPolicy
+	Time           time.Time
+}
+
+// AliasError is a failure of the alias manager; Op is the JPush call that
+// failed and Err its error, usually an *APIError.
+type AliasError struct {
+	Op              string
+	Alias           string
+	RegistrationIds []string
+	Err             error
+}
+
+func (e *AliasError) Error() string {
+	return fmt.Sprintf("alias %s: %s %v: %v", e.Alias, e.Op, e.RegistrationIds, e.Err)
+}
+
+func (e *AliasError) Unwrap() error {
+	return e.Err
+}
+
+// AliasManager enforces an AliasPolicy once a device is bound to an alias.
+// Set it on Client.Aliases to apply it on every SetDevice.
+type AliasManager struct {
+	client DeviceClient
+	policy AliasPolicy
+	// PolicyFor, when set, picks the policy per alias instead, e.g. single
+	// device for accounts using banking flows.
+	PolicyFor func(alias string) AliasPolicy
+	// Audit is called with every displacement.
+	Audit func(d *AliasDisplacement)
+	now   func() time.Time
+}
+
+func NewAliasManager(c DeviceClient, policy AliasPolicy) (*AliasManager, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	return &AliasManager{client: c, policy: policy, now: time.Now}, nil
+}
+
+func (m *AliasManager) policyFor(alias string) AliasPolicy {
+	if m.PolicyFor != nil {
+		return m.PolicyFor(alias)
+	}
+	return m.policy
+}
+
+// Enforce unbinds from alias the devices other than registrationId, which
+// was just bound, that are over the policy's limit, oldest first. Devices are
+// ordered by their registry LastSeen, newest first; devices the registry
+// does not know count as the oldest, and without a registry they keep the
+// order JPush lists them in. It returns nil when nothing was displaced.
+func (m *AliasManager) Enforce(alias, registrationId string) (*AliasDisplacement, error) {
+	policy := m.policyFor(alias)
+	if err := policy.validate(); err != nil {
+		return nil, &AliasError{Op: "policy", Alias: alias, Err: err}
+	}
+	return m.displace(alias, registrationId, policy, policy.MaxDevices-1)
+}
+
+// MakeRoom unbinds the oldest device when alias is at JPush's cap of
+// MaxAliasDevices, which would make binding registrationId fail. Only that
+// one device is displaced; Enforce applies the policy once the bind went
+// through. It returns nil when there was room.
+func (m *AliasManager) MakeRoom(alias, registrationId string) (*AliasDisplacement, error) {
+	policy := m.policyFor(alias)
+	if err := policy.validate(); err != nil {
+		return nil, &AliasError{Op: "policy", Alias: alias, Err: err}
+	}
+	return m.displace(alias, registrationId, policy, MaxAliasDevices-1)
+}
+
+// displace unbinds all but the keep newest devices bound to alias other
+// than registrationId.
+func (m *AliasManager) displace(alias, registrationId string, policy AliasPolicy, keep int) (*AliasDisplacement, error) {
+	bound, err := m.client.AliasGet(alias, nil)
+	if err != nil {
+		return nil, &AliasError{Op: "get", Alias: alias, Err: err}
+	}
+	var others []string
+	for _, regId := range bound.RegistrationIds {
+		if regId != registrationId {
+			others = append(others, regId)
+		}
+	}
+	if len(others) <= keep {
+		return nil, nil
+	}
+
+	m.sortNewest(others)
+	d := &AliasDisplacement{
+		Alias:          alias,
+		RegistrationId: registrationId,
+		Displaced:      others[keep:],
+		Policy:         policy,
+		Time:           m.now(),
+	}
+	if err := m.client.AliasUnbind(alias, d.Displaced); err != nil {
+		return nil, &AliasError{Op: "unbind", Alias: alias, RegistrationIds: d.Displaced, Err: err}
+	}
+	if m.Audit != nil {
+		m.Audit(d)
+	}
+	return d, nil
+}
+
+func (m *AliasManager) sortNewest(regIds []string) {
+	if m.client.Registry == nil {
+		return
+	}
+	seen := make(map[string]time.Time, len(regIds))
+	for _, regId := range regIds {
+		if e, err := m.client.Registry.Get(regId); err == nil {
+			seen[regId] = e.LastSeen
+		}
+	}
+	sort.SliceStable(regIds, func(i, j int) bool {
+		return seen[regIds[i]].After(seen[regIds[j]])
+	})
+}
@@ -0,0 +1,213 @@
+package jpush
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sustring/push/common"
+)
+
+func TestAliasManager(t *testing.T) {
+	// JPush lists the device the registry does not know first
+	bound := []string{"unknown", "old", "newer", "rid"}
+	var unbound []string
+	failUnbind, failSet := false, false
+	var setCalled bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(&Alias{RegistrationIds: bound})
+		case strings.HasPrefix(r.URL.Path, "/v3/aliases/"):
+			if failUnbind {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"code":7002,"message":"bad request"}}`))
+				return
+			}
+			var body struct {
+				RegistrationIds struct {
+					Remove []string `json:"remove"`
+				} `json:"registration_ids"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			unbound = body.RegistrationIds.Remove
+			w.Write([]byte(`{}`))
+		default:
+			setCalled = true
+			if failSet {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	registry := NewRegistry(NewMemoryRegistryStore())
+	now := time.Unix(1600000000, 0)
+	registry.now = func() time.Time { return now }
+	registry.Seen("old", PlatformAndroid)
+	now = now.Add(time.Hour)
+	registry.Seen("newer", PlatformIOS)
+
+	device := &DeviceClient{BaseClient: &BaseClient{}, url: server.URL, Registry: registry}
+	var audit []*AliasDisplacement
+	m, err := NewAliasManager(*device, AliasKeepNewest(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Audit = func(d *AliasDisplacement) { audit = append(audit, d) }
+	c := Client{DeviceClient: device, Aliases: m}
+
+	if _, err := c.SetDevice(&common.SetDeviceInput{Id: "rid", Alias: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	// "rid" plus the newest other device stay bound; the unknown device
+	// counts as the oldest
+	if strings.Join(unbound, ",") != "old,unknown" || !setCalled {
+		t.Fatalf("unexpected unbind %v", unbound)
+	}
+	if len(audit) != 1 || audit[0].RegistrationId != "rid" || audit[0].Alias != "u1" || audit[0].Policy.MaxDevices != 2 {
+		t.Fatalf("unexpected audit %+v", audit)
+	}
+
+	m.PolicyFor = func(alias string) AliasPolicy {
+		if alias == "bank" {
+			return AliasSingleDevice
+		}
+		return AliasUnlimited
+	}
+	if d, err := m.Enforce("u1", "rid"); d != nil || err != nil {
+		t.Fatalf("unlimited alias was enforced: %+v %v", d, err)
+	}
+
+	failUnbind, setCalled = true, false
+	_, err = c.SetDevice(&common.SetDeviceInput{Id: "rid", Alias: "bank"})
+	var aliasErr *AliasError
+	var apiErr *APIError
+	if !errors.As(err, &aliasErr) || aliasErr.Op != "unbind" || len(aliasErr.RegistrationIds) != 3 || !errors.As(err, &apiErr) || apiErr.Code != 7002 {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !setCalled || len(audit) != 1 {
+		t.Fatal("device not bound before the unbind, or audited after it failed")
+	}
+
+	// a failed bind displaces no one
+	failUnbind, failSet, unbound = false, true, nil
+	if _, err := c.SetDevice(&common.SetDeviceInput{Id: "rid", Alias: "bank"}); err == nil || unbound != nil {
+		t.Fatalf("devices unbound after a failed bind: %v, %v", unbound, err)
+	}
+
+	for _, n := range []int{0, -1, MaxAliasDevices + 1} {
+		if _, err := NewAliasManager(*device, AliasKeepNewest(n)); err == nil {
+			t.Errorf("NewAliasManager accepted MaxDevices %d", n)
+		}
+	}
+	m.PolicyFor = func(alias string) AliasPolicy { return AliasPolicy{} }
+	if _, err := m.Enforce("u1", "rid"); !errors.As(err, &aliasErr) || aliasErr.Op != "policy" {
+		t.Fatalf("unexpected error for an invalid policy %v", err)
+	}
+}
+
+func TestSetDeviceCleanAliasSkipsPolicy(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	device := &DeviceClient{BaseClient: &BaseClient{}, url: server.URL}
+	m, err := NewAliasManager(*device, AliasSingleDevice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{DeviceClient: device, Aliases: m}
+	if _, err := c.SetDevice(&common.SetDeviceInput{Id: "rid", Alias: "u1", CleanAlias: true}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(methods, ",") != "POST /v3/devices/rid" {
+		t.Fatalf("expected only the device update, got %v", methods)
+	}
+}
+
+func TestSetDeviceMakesRoomAtCap(t *testing.T) {
+	var bound, calls []string
+	for i := 0; i < MaxAliasDevices; i++ {
+		bound = append(bound, fmt.Sprintf("d%d", i))
+	}
+	failSet := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			calls = append(calls, "get")
+			json.NewEncoder(w).Encode(&Alias{RegistrationIds: bound})
+		case strings.HasPrefix(r.URL.Path, "/v3/aliases/"):
+			var body struct {
+				RegistrationIds struct {
+					Remove []string `json:"remove"`
+				} `json:"registration_ids"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			calls = append(calls, "unbind "+strings.Join(body.RegistrationIds.Remove, ","))
+			removed := strings.Join(body.RegistrationIds.Remove, ",") + ","
+			var left []string
+			for _, regId := range bound {
+				if !strings.Contains(removed, regId+",") {
+					left = append(left, regId)
+				}
+			}
+			bound = left
+			w.Write([]byte(`{}`))
+		default:
+			calls = append(calls, "set")
+			if failSet || len(bound) >= MaxAliasDevices {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"code":7020,"message":"alias is bound to too many devices"}}`))
+				return
+			}
+			bound = append(bound, "rid")
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	registry := NewRegistry(NewMemoryRegistryStore())
+	now := time.Unix(1600000000, 0)
+	registry.now = func() time.Time { return now }
+	for i := len(bound) - 1; i >= 0; i-- {
+		now = now.Add(time.Minute)
+		registry.Seen(bound[i], PlatformAndroid)
+	}
+	device := &DeviceClient{BaseClient: &BaseClient{}, url: server.URL, Registry: registry}
+	m, err := NewAliasManager(*device, AliasUnlimited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{DeviceClient: device, Aliases: m}
+
+	if _, err := c.SetDevice(&common.SetDeviceInput{Id: "rid", Alias: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	// d9 was seen first, so it is the oldest
+	if want := "get,unbind d9,set,get"; strings.Join(calls, ",") != want {
+		t.Fatalf("got calls %v, want %s", calls, want)
+	}
+	if len(bound) != MaxAliasDevices || bound[len(bound)-1] != "rid" {
+		t.Fatalf("unexpected bound devices %v", bound)
+	}
+
+	// below the cap nothing is unbound before the bind
+	calls, bound, failSet = nil, bound[:3], true
+	if _, err := c.SetDevice(&common.SetDeviceInput{Id: "rid2", Alias: "u1"}); err == nil {
+		t.Fatal("expected the failed bind's error")
+	}
+	if want := "get,set"; strings.Join(calls, ",") != want {
+		t.Fatalf("got calls %v, want %s", calls, want)
+	}
+}
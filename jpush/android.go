@@ -0,0 +1,98 @@
+package jpush
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sustring/push/common"
+)
+
+// AlertType is the Android alert_type bitmask; AlertDefault uses all three.
+type AlertType int
+
+const (
+	AlertDefault AlertType = -1
+	AlertSound   AlertType = 1
+	AlertVibrate AlertType = 2
+	AlertLight   AlertType = 4
+)
+
+func NewAndroidNotification(alert string) *NotificationAndroid {
+	return &NotificationAndroid{Alert: alert}
+}
+
+func (n *NotificationAndroid) WithTitle(title string) *NotificationAndroid {
+	n.Title = title
+	return n
+}
+
+func (n *NotificationAndroid) WithBigText(text string) *NotificationAndroid {
+	n.Style = AndroidStyleBigText
+	n.BigText = text
+	return n
+}
+
+// WithInbox sets the inbox style. Lines are keyed so they keep their order
+// when the SDK sorts the inbox object.
+func (n *NotificationAndroid) WithInbox(lines ...string) *NotificationAndroid {
+	n.Style = AndroidStyleInbox
+	n.Inbox = make(map[string]interface{}, len(lines))
+	for i, line := range lines {
+		n.Inbox[fmt.Sprintf("line%02d", i+1)] = line
+	}
+	return n
+}
+
+// WithBigPicture sets the big picture style; path is an http(s) url or a
+// media id returned by JPush's image upload.
+func (n *NotificationAndroid) WithBigPicture(path string) *NotificationAndroid {
+	n.Style = AndroidStyleBigPic
+	n.BigPicPath = path
+	return n
+}
+
+func (n *NotificationAndroid) WithAlertType(t AlertType) *NotificationAndroid {
+	n.AlertType = int(t)
+	return n
+}
+
+// WithIntent sets the page opened on click, either an intent uri built by
+// ActivityIntent or a deeplink such as "myapp://orders/42".
+func (n *NotificationAndroid) WithIntent(url string) *NotificationAndroid {
+	n.Intent = map[string]interface{}{"url": url}
+	return n
+}
+
+func (n *NotificationAndroid) WithExtras(extras map[string]interface{}) *NotificationAndroid {
+	n.Extras = extras
+	return n
+}
+
+// ActivityIntent builds the intent uri JPush expects for opening a specific
+// activity, e.g. ActivityIntent("com.example", "com.example.OrderActivity").
+func ActivityIntent(pkg, activity string) string {
+	if strings.HasPrefix(activity, ".") {
+		activity = pkg + activity
+	}
+	return "intent:#Intent;component=" + pkg + "/" + activity + ";end"
+}
+
+func applyRichContent(n *NotificationAndroid, rich *common.RichContent) {
+	if rich == nil {
+		return
+	}
+	if rich.Title != "" {
+		n.WithTitle(rich.Title)
+	}
+	switch {
+	case rich.Picture != "":
+		n.WithBigPicture(rich.Picture)
+	case len(rich.InboxLines) > 0:
+		n.WithInbox(rich.InboxLines...)
+	case rich.BigText != "":
+		n.WithBigText(rich.BigText)
+	}
+	if rich.DeepLink != "" {
+		n.WithIntent(rich.DeepLink)
+	}
+}
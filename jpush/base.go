@@ -8,6 +8,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"runtime"
+	"strconv"
+	"time"
 )
 
 type BaseClient struct {
@@ -48,12 +50,13 @@ func (c BaseClient) Request(method, link string, body io.Reader, isGroup bool) (
 	if err != nil {
 		return nil, err
 	}
-	return &Response{statusCode: resp.StatusCode, status: resp.Status, data: buf}, nil
+	return &Response{statusCode: resp.StatusCode, status: resp.Status, header: resp.Header, data: buf}, nil
 }
 
 type Response struct {
 	statusCode int
 	status     string
+	header     http.Header
 	data       []byte
 }
 
@@ -80,3 +83,57 @@ func (r Response) StatusCode() int {
 func (r Response) Status() string {
 	return r.status
 }
+
+// APIError is a non-200 response from JPush, with the error code and
+// message of its body when there is one.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Code       int
+	Message    string
+	// RetryAfter is how long until the rate limit resets, from the
+	// X-Rate-Limit-Reset header of a 429 response.
+	RetryAfter time.Duration
+}
+
+func newAPIError(resp *Response) *APIError {
+	e := &APIError{StatusCode: resp.statusCode, Status: resp.status}
+	var body struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(resp.data, &body) == nil {
+		e.Code, e.Message = body.Error.Code, body.Error.Message
+	}
+	if seconds, err := strconv.Atoi(resp.header.Get("X-Rate-Limit-Reset")); err == nil && resp.statusCode == http.StatusTooManyRequests {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return e.Status
+	}
+	return fmt.Sprintf("%s: %d %s", e.Status, e.Code, e.Message)
+}
+
+// Temporary reports whether the same request may succeed later: the app
+// was rate limited or JPush failed.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// chunkStrings splits list into consecutive slices of at most size items.
+func chunkStrings(list []string, size int) [][]string {
+	if len(list) == 0 {
+		return nil
+	}
+	chunks := make([][]string, 0, (len(list)+size-1)/size)
+	for size < len(list) {
+		list, chunks = list[size:], append(chunks, list[:size:size])
+	}
+	return append(chunks, list)
+}
@@ -0,0 +1,61 @@
+package jpush
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIError(t *testing.T) {
+	resp := &Response{
+		statusCode: http.StatusTooManyRequests,
+		status:     "429 Too Many Requests",
+		header:     http.Header{"X-Rate-Limit-Reset": {"12"}},
+		data:       []byte(`{"error":{"code":2002,"message":"Rate limit exceeded"}}`),
+	}
+	e := newAPIError(resp)
+	if e.Code != 2002 || e.RetryAfter != 12*time.Second || !e.Temporary() {
+		t.Fatalf("unexpected error %+v", e)
+	}
+	if e.Error() != "429 Too Many Requests: 2002 Rate limit exceeded" {
+		t.Fatalf("unexpected message %q", e.Error())
+	}
+
+	e = newAPIError(&Response{statusCode: http.StatusNotFound, status: "404 Not Found", data: []byte("not json")})
+	if e.Error() != "404 Not Found" || e.Temporary() {
+		t.Fatalf("unexpected error %+v", e)
+	}
+}
+
+// rateLimited answers every request with JPush's rate limit error.
+func rateLimited() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Rate-Limit-Reset", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"code":2002,"message":"Rate limit exceeded"}}`))
+	}))
+}
+
+func TestReportAPIErrors(t *testing.T) {
+	server := rateLimited()
+	defer server.Close()
+	c := ReportClient{BaseClient: &BaseClient{}, url: server.URL}
+
+	_, err := c.MessageStatus(&MessageStatusPayload{MsgId: "1", RegistrationIds: []string{"rid"}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.Temporary() || apiErr.RetryAfter != 3*time.Second {
+		t.Fatalf("MessageStatus: expected a rate limit APIError, got %v", err)
+	}
+	calls := map[string]func() error{
+		"MessagesDetail": func() error { _, err := c.MessagesDetail([]string{"1"}); return err },
+		"Received":       func() error { _, err := c.Received([]string{"1"}); return err },
+		"Users":          func() error { _, err := c.Users(Days(time.Now(), 1)); return err },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.As(err, &apiErr) || !apiErr.Temporary() {
+			t.Errorf("%s: expected a rate limit APIError, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package jpush
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CallbackType is the event bitmask used both in PushCallback.Type, to ask
+// JPush for events, and in the events it posts back.
+type CallbackType int
+
+const (
+	CallbackReceived CallbackType = 1
+	CallbackClicked  CallbackType = 2
+	CallbackSent     CallbackType = 8 // handed to JPush or a vendor channel
+)
+
+type CallbackEvent struct {
+	Type           CallbackType
+	MsgId          string
+	AppKey         string
+	RegistrationId string
+	Platform       Platform
+	Channel        string // jpush, xiaomi, huawei, ...
+	Time           time.Time
+	Params         map[string]interface{} // PushCallback.Params echoed back
+}
+
+// DefaultCallbackBodyBytes is the largest callback body accepted when
+// CallbackHandler.MaxBodyBytes is not set.
+const DefaultCallbackBodyBytes = 1 << 20
+
+// CallbackHandler is an http.Handler receiving JPush delivery callbacks.
+// Each verified event is passed to the handlers registered for its type and
+// then to the channel set with Notify.
+type CallbackHandler struct {
+	// Verify authenticates a request before its body is decoded, and the
+	// url check JPush makes with a GET, whose body is nil. A nil Verify
+	// accepts every request.
+	Verify func(r *http.Request, body []byte) error
+	// MaxBodyBytes caps the body read, as the url is public; larger bodies
+	// are answered 413. Zero means DefaultCallbackBodyBytes.
+	MaxBodyBytes int64
+
+	mu       sync.RWMutex
+	handlers map[CallbackType][]func(*CallbackEvent)
+	events   chan<- *CallbackEvent
+}
+
+func NewCallbackHandler(verify func(r *http.Request, body []byte) error) *CallbackHandler {
+	return &CallbackHandler{
+		Verify:   verify,
+		handlers: make(map[CallbackType][]func(*CallbackEvent)),
+	}
+}
+
+// Handle registers fn for every event type set in t.
+func (h *CallbackHandler) Handle(t CallbackType, fn func(*CallbackEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, bit := range []CallbackType{CallbackReceived, CallbackClicked, CallbackSent} {
+		if t&bit != 0 {
+			h.handlers[bit] = append(h.handlers[bit], fn)
+		}
+	}
+}
+
+// Notify sends every event to ch. Delivery blocks until ch accepts the
+// event or the callback request is cancelled.
+func (h *CallbackHandler) Notify(ch chan<- *CallbackEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = ch
+}
+
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// JPush checks a callback url by expecting its echostr back. It is
+	// echoed as plain text only once the request is verified, so it cannot
+	// be used to reflect markup.
+	if r.Method == http.MethodGet {
+		if h.Verify != nil {
+			if err := h.Verify(r, nil); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Write([]byte(r.URL.Query().Get("echostr")))
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	limit := h.MaxBodyBytes
+	if limit <= 0 {
+		limit = DefaultCallbackBodyBytes
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		status := http.StatusBadRequest
+		if int64(len(body)) >= limit {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if h.Verify != nil {
+		if err := h.Verify(r, body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+	events, err := ParseCallback(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, e := range events {
+		h.mu.RLock()
+		handlers, ch := h.handlers[e.Type], h.events
+		h.mu.RUnlock()
+		for _, fn := range handlers {
+			fn(e)
+		}
+		if ch != nil {
+			select {
+			case ch <- e:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type callbackBody struct {
+	MsgId          MsgId                  `json:"msg_id"`
+	AppKey         string                 `json:"appkey"`
+	RegistrationId string                 `json:"registration_id"`
+	Platform       string                 `json:"platform"`
+	Channel        string                 `json:"channel"`
+	Type           CallbackType           `json:"type"`
+	Time           int64                  `json:"time"`
+	Params         map[string]interface{} `json:"params"`
+}
+
+// ParseCallback decodes a callback body, which holds either one event or an
+// array of them.
+func ParseCallback(body []byte) ([]*CallbackEvent, error) {
+	var list []callbackBody
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, err
+		}
+	} else {
+		var one callbackBody
+		if err := json.Unmarshal(body, &one); err != nil {
+			return nil, err
+		}
+		list = append(list, one)
+	}
+
+	events := make([]*CallbackEvent, 0, len(list))
+	for _, v := range list {
+		if v.MsgId == "" {
+			return nil, errors.New("callback without msg_id")
+		}
+		events = append(events, &CallbackEvent{
+			Type:           v.Type,
+			MsgId:          string(v.MsgId),
+			AppKey:         v.AppKey,
+			RegistrationId: v.RegistrationId,
+			Platform:       callbackPlatform(v.Platform),
+			Channel:        v.Channel,
+			Time:           callbackTime(v.Time),
+			Params:         v.Params,
+		})
+	}
+	return events, nil
+}
+
+var callbackPlatforms = map[string]Platform{
+	"a": PlatformAndroid,
+	"i": PlatformIOS,
+	"q": PlatformQuickApp,
+	"h": PlatformHmos,
+}
+
+func callbackPlatform(s string) Platform {
+	if p, ok := callbackPlatforms[s]; ok {
+		return p
+	}
+	return Platform(s)
+}
+
+// callbackTime accepts both unix seconds and milliseconds.
+func callbackTime(t int64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	if t > 1e12 {
+		return time.Unix(0, t*int64(time.Millisecond))
+	}
+	return time.Unix(t, 0)
+}
+
+// VerifyAuthorization accepts requests carrying the app's basic
+// authorization header, the same one the client sends to JPush.
+func VerifyAuthorization(appKey, masterSecret string) func(r *http.Request, body []byte) error {
+	want := BaseClient{AppKey: appKey, MasterSecret: masterSecret}.GetAuthorization(false)
+	return func(r *http.Request, body []byte) error {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+			return errors.New("invalid callback authorization")
+		}
+		return nil
+	}
+}
+
+// VerifyParam accepts requests whose events echo back the params entry
+// key=value, which is set in PushCallback.Params when pushing. The url check
+// carries no events, so it fails; combine it with VerifyAuthorization where
+// JPush checks the url.
+func VerifyParam(key, value string) func(r *http.Request, body []byte) error {
+	return func(r *http.Request, body []byte) error {
+		events, err := ParseCallback(body)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			got, _ := e.Params[key].(string)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(value)) != 1 {
+				return errors.New("invalid callback param " + key)
+			}
+		}
+		return nil
+	}
+}
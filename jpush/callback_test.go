@@ -0,0 +1,78 @@
+package jpush
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCallbackHandler(t *testing.T) {
+	h := NewCallbackHandler(VerifyParam("token", "s3cret"))
+	var clicked []*CallbackEvent
+	h.Handle(CallbackClicked, func(e *CallbackEvent) { clicked = append(clicked, e) })
+	events := make(chan *CallbackEvent, 2)
+	h.Notify(events)
+
+	body := `[{"msg_id":67554217262909280,"registration_id":"140fe1da9e038c6b343","platform":"a","channel":"xiaomi","type":1,"time":1600000000,"params":{"token":"s3cret"}},
+		{"msg_id":"67554217262909280","registration_id":"140fe1da9e038c6b343","platform":"a","type":2,"time":1600000005000,"params":{"token":"s3cret"}}]`
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if len(clicked) != 1 || clicked[0].Time.Unix() != 1600000005 {
+		t.Fatalf("unexpected clicked events %+v", clicked)
+	}
+	e := <-events
+	if e.Type != CallbackReceived || e.MsgId != "67554217262909280" || e.Platform != PlatformAndroid || e.Channel != "xiaomi" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	w = httptest.NewRecorder()
+	forged := strings.Replace(body, "s3cret", "guess", -1)
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(forged)))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("forged callback accepted with status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/callback?echostr=%3Cscript%3E", nil))
+	if w.Code != http.StatusUnauthorized || strings.Contains(w.Body.String(), "<script>") {
+		t.Fatalf("unverified url check echoed with status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestCallbackEcho(t *testing.T) {
+	h := NewCallbackHandler(VerifyAuthorization("key", "secret"))
+	r := httptest.NewRequest(http.MethodGet, "/callback?echostr=%3Cb%3Ehi", nil)
+	r.Header.Set("Authorization", BaseClient{AppKey: "key", MasterSecret: "secret"}.GetAuthorization(false))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "<b>hi" {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatal("missing nosniff")
+	}
+}
+
+func TestCallbackBodyLimit(t *testing.T) {
+	h := NewCallbackHandler(nil)
+	h.MaxBodyBytes = 64
+	body := `{"msg_id":"1","registration_id":"` + strings.Repeat("a", 64) + `","type":1}`
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized callback answered %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(`{"msg_id":"1","type":1}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("small callback answered %d: %s", w.Code, w.Body)
+	}
+}
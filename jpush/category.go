@@ -0,0 +1,90 @@
+package jpush
+
+import "github.com/sustring/push/common"
+
+// VendorChannelIds holds the notification channel ids an app registered
+// with Xiaomi and OPPO for one category. Those vendors have no fixed
+// category names, so the ids have to come from the app's console setup.
+type VendorChannelIds struct {
+	Xiaomi string
+	Oppo   string
+}
+
+type vendorCategory struct {
+	huawei      string
+	importance  string
+	oppo        string
+	notifyLevel int
+	vivo        string
+	vivoClass   int // 0 operational, 1 system
+	honor       string
+	hmos        string
+}
+
+var vendorCategories = map[common.NotificationCategory]vendorCategory{
+	common.CategoryTransactional: {
+		huawei:      "ACCOUNT",
+		importance:  "NORMAL",
+		oppo:        "ORDER",
+		notifyLevel: 2,
+		vivo:        "ORDER",
+		vivoClass:   1,
+		honor:       "NORMAL",
+		hmos:        "ACCOUNT",
+	},
+	common.CategorySocial: {
+		huawei:      "IM",
+		importance:  "NORMAL",
+		oppo:        "IM",
+		notifyLevel: 16,
+		vivo:        "IM",
+		vivoClass:   1,
+		honor:       "NORMAL",
+		hmos:        "IM",
+	},
+	common.CategorySystem: {
+		huawei:      "DEVICE_REMINDER",
+		importance:  "NORMAL",
+		oppo:        "DEVICE_REMIND",
+		notifyLevel: 2,
+		vivo:        "DEVICE_REMINDER",
+		vivoClass:   1,
+		honor:       "NORMAL",
+		hmos:        "DEVICE_REMINDER",
+	},
+	common.CategoryMarketing: {
+		huawei:      "MARKETING",
+		importance:  "LOW",
+		oppo:        "MARKETING",
+		notifyLevel: 1,
+		vivo:        "MARKETING",
+		vivoClass:   0,
+		honor:       "LOW",
+		hmos:        "MARKETING",
+	},
+}
+
+// CategoryChannelOption maps a provider-neutral category to the vendor
+// fields that decide which delivery lane a message is put in. Only the
+// category and channel fields are set; the distribution is left to the app
+// configuration. It returns nil for CategoryDefault or an unknown category.
+func CategoryChannelOption(category common.NotificationCategory, ids VendorChannelIds) *ThirdPartyChannelOption {
+	v, ok := vendorCategories[category]
+	if !ok {
+		return nil
+	}
+	vivoClass := v.vivoClass
+	return NewThirdPartyChannel().
+		WithXiaomi(XiaomiChannel{ChannelId: ids.Xiaomi}).
+		WithHuawei(HuaweiChannel{Importance: v.importance, Category: v.huawei}).
+		WithOppo(OppoChannel{ChannelId: ids.Oppo, Category: v.oppo, NotifyLevel: v.notifyLevel}).
+		WithVivo(VivoChannel{Classification: &vivoClass, Category: v.vivo}).
+		WithHonor(HonorChannel{Importance: v.honor}).
+		WithHmos(HmosChannel{Category: v.hmos})
+}
+
+// HmosCategory is the HarmonyOS notification category for category, or ""
+// for CategoryDefault or an unknown category.
+func HmosCategory(category common.NotificationCategory) string {
+	return vendorCategories[category].hmos
+}
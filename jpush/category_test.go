@@ -0,0 +1,68 @@
+package jpush
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sustring/push/common"
+)
+
+func TestCategoryChannelOption(t *testing.T) {
+	ids := VendorChannelIds{Xiaomi: "mi-1", Oppo: "oppo-1"}
+	cases := []struct {
+		category common.NotificationCategory
+		want     string
+	}{
+		{common.CategoryTransactional, `{"xiaomi":{"channel_id":"mi-1"},` +
+			`"huawei":{"importance":"NORMAL","category":"ACCOUNT"},` +
+			`"oppo":{"channel_id":"oppo-1","category":"ORDER","notify_level":2},` +
+			`"vivo":{"classification":1,"category":"ORDER"},` +
+			`"honor":{"importance":"NORMAL"},` +
+			`"hmos":{"category":"ACCOUNT"}}`},
+		{common.CategorySocial, `{"xiaomi":{"channel_id":"mi-1"},` +
+			`"huawei":{"importance":"NORMAL","category":"IM"},` +
+			`"oppo":{"channel_id":"oppo-1","category":"IM","notify_level":16},` +
+			`"vivo":{"classification":1,"category":"IM"},` +
+			`"honor":{"importance":"NORMAL"},` +
+			`"hmos":{"category":"IM"}}`},
+		{common.CategorySystem, `{"xiaomi":{"channel_id":"mi-1"},` +
+			`"huawei":{"importance":"NORMAL","category":"DEVICE_REMINDER"},` +
+			`"oppo":{"channel_id":"oppo-1","category":"DEVICE_REMIND","notify_level":2},` +
+			`"vivo":{"classification":1,"category":"DEVICE_REMINDER"},` +
+			`"honor":{"importance":"NORMAL"},` +
+			`"hmos":{"category":"DEVICE_REMINDER"}}`},
+		{common.CategoryMarketing, `{"xiaomi":{"channel_id":"mi-1"},` +
+			`"huawei":{"importance":"LOW","category":"MARKETING"},` +
+			`"oppo":{"channel_id":"oppo-1","category":"MARKETING","notify_level":1},` +
+			`"vivo":{"classification":0,"category":"MARKETING"},` +
+			`"honor":{"importance":"LOW"},` +
+			`"hmos":{"category":"MARKETING"}}`},
+	}
+	for _, c := range cases {
+		buf, err := json.Marshal(CategoryChannelOption(c.category, ids))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != c.want {
+			t.Errorf("category %d: got %s, want %s", c.category, buf, c.want)
+		}
+	}
+	if CategoryChannelOption(common.CategoryDefault, ids) != nil || HmosCategory(common.CategoryDefault) != "" {
+		t.Fatal("expected no vendor fields for the default category")
+	}
+}
+
+func TestBuildPushPayloadHmosCategoryFromCategory(t *testing.T) {
+	in := &common.PushMessageInput{Platform: common.Hmos, Presentation: true, Alert: "hi", Category: common.CategorySocial}
+	payload, _, err := Client{}.buildPushPayload(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Notification.Hmos.Category != "IM" {
+		t.Fatalf("unexpected hmos category %q", payload.Notification.Hmos.Category)
+	}
+	in.HmosCategory = "EXPRESS"
+	if payload, _, err = (Client{}).buildPushPayload(in); err != nil || payload.Notification.Hmos.Category != "EXPRESS" {
+		t.Fatalf("explicit hmos category not kept: %v", err)
+	}
+}
@@ -3,15 +3,17 @@ package jpush
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
-	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
 type DeviceClient struct {
 	*BaseClient
 	url string
+	// Registry, when set, records every successful device, tag and alias
+	// update. Failures to record are not returned; Reconcile catches them.
+	Registry *Registry
 }
 
 type Device struct {
@@ -21,13 +23,16 @@ type Device struct {
 }
 
 func (c DeviceClient) DeviceView(registrationId string) (*Device, error) {
-	link := c.url + "/v3/devices/" + registrationId
+	if err := validateRegistrationId(registrationId); err != nil {
+		return nil, err
+	}
+	link := c.url + "/v3/devices/" + url.PathEscape(registrationId)
 	resp, err := c.Request("GET", link, nil, false)
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return nil, errors.New(resp.Status())
+		return nil, newAPIError(resp)
 	}
 	var out Device
 	err = json.Unmarshal(resp.Bytes(), &out)
@@ -37,10 +42,12 @@ func (c DeviceClient) DeviceView(registrationId string) (*Device, error) {
 	return &out, nil
 }
 
+// DeviceSettingPayload changes only the fields that are set: nil leaves a
+// field unchanged, an empty string clears it.
 type DeviceSettingPayload struct {
-	Tags   interface{} `json:"tags"` // empty string or DeviceSettingRequestTags
-	Alias  string      `json:"alias"`
-	Mobile string      `json:"mobile"`
+	Tags   interface{} `json:"tags,omitempty"` // empty string or *DeviceSettingRequestTags
+	Alias  *string     `json:"alias,omitempty"`
+	Mobile *string     `json:"mobile,omitempty"`
 }
 
 type DeviceSettingRequestTags struct {
@@ -48,8 +55,76 @@ type DeviceSettingRequestTags struct {
 	Remove []string `json:"remove,omitempty"`
 }
 
+func NewDeviceSetting() *DeviceSettingPayload {
+	return &DeviceSettingPayload{}
+}
+
+func (p *DeviceSettingPayload) SetAlias(alias string) *DeviceSettingPayload {
+	p.Alias = &alias
+	return p
+}
+
+func (p *DeviceSettingPayload) ClearAlias() *DeviceSettingPayload {
+	return p.SetAlias("")
+}
+
+func (p *DeviceSettingPayload) SetMobile(mobile string) *DeviceSettingPayload {
+	p.Mobile = &mobile
+	return p
+}
+
+func (p *DeviceSettingPayload) ClearMobile() *DeviceSettingPayload {
+	return p.SetMobile("")
+}
+
+// AddTags adds to the tags, unless they are being cleared.
+func (p *DeviceSettingPayload) AddTags(tags ...string) *DeviceSettingPayload {
+	if len(tags) == 0 {
+		return p
+	}
+	if t := p.requestTags(); t != nil {
+		t.Add = append(t.Add, tags...)
+	}
+	return p
+}
+
+// RemoveTags removes from the tags, unless they are being cleared.
+func (p *DeviceSettingPayload) RemoveTags(tags ...string) *DeviceSettingPayload {
+	if len(tags) == 0 {
+		return p
+	}
+	if t := p.requestTags(); t != nil {
+		t.Remove = append(t.Remove, tags...)
+	}
+	return p
+}
+
+// ClearTags removes every tag, dropping tags added or removed before.
+func (p *DeviceSettingPayload) ClearTags() *DeviceSettingPayload {
+	p.Tags = ""
+	return p
+}
+
+func (p *DeviceSettingPayload) requestTags() *DeviceSettingRequestTags {
+	switch t := p.Tags.(type) {
+	case nil:
+		tags := &DeviceSettingRequestTags{}
+		p.Tags = tags
+		return tags
+	case *DeviceSettingRequestTags:
+		return t
+	}
+	return nil
+}
+
 func (c DeviceClient) DeviceSet(registrationId string, payload *DeviceSettingPayload) error {
-	link := c.url + "/v3/devices/" + registrationId
+	if err := validateRegistrationId(registrationId); err != nil {
+		return err
+	}
+	if err := payload.validate(); err != nil {
+		return err
+	}
+	link := c.url + "/v3/devices/" + url.PathEscape(registrationId)
 	buf, err := json.Marshal(payload)
 	if err != nil {
 		return err
@@ -59,7 +134,10 @@ func (c DeviceClient) DeviceSet(registrationId string, payload *DeviceSettingPay
 		return err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return errors.New(resp.Status())
+		return newAPIError(resp)
+	}
+	if c.Registry != nil {
+		c.Registry.recordDeviceSet(registrationId, payload)
 	}
 	return nil
 }
@@ -69,7 +147,10 @@ type Alias struct {
 }
 
 func (c DeviceClient) AliasGet(alias string, platforms []string) (*Alias, error) {
-	link := c.url + "/v3/aliases/" + alias
+	if err := ValidateAlias(alias); err != nil {
+		return nil, err
+	}
+	link := c.url + "/v3/aliases/" + url.PathEscape(alias)
 	if len(platforms) > 0 {
 		link += "?platform=" + strings.Join(platforms, ",")
 	}
@@ -78,7 +159,7 @@ func (c DeviceClient) AliasGet(alias string, platforms []string) (*Alias, error)
 		return nil, err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return nil, errors.New(resp.Status())
+		return nil, newAPIError(resp)
 	}
 	var out Alias
 	err = json.Unmarshal(resp.Bytes(), &out)
@@ -89,19 +170,28 @@ func (c DeviceClient) AliasGet(alias string, platforms []string) (*Alias, error)
 }
 
 func (c DeviceClient) AliasDelete(alias string) error {
-	link := c.url + "/v3/aliases/" + alias
+	if err := ValidateAlias(alias); err != nil {
+		return err
+	}
+	link := c.url + "/v3/aliases/" + url.PathEscape(alias)
 	resp, err := c.Request("DELETE", link, nil, false)
 	if err != nil {
 		return err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return errors.New(resp.Status())
+		return newAPIError(resp)
+	}
+	if c.Registry != nil {
+		c.Registry.recordAliasDelete(alias, nil)
 	}
 	return nil
 }
 
 func (c DeviceClient) AliasUnbind(alias string, registrationIds []string) error {
-	link := c.url + "/v3/aliases/" + alias
+	if err := ValidateAlias(alias); err != nil {
+		return err
+	}
+	link := c.url + "/v3/aliases/" + url.PathEscape(alias)
 	params := make(map[string]interface{})
 	params["registration_ids"] = map[string][]string{"remove": registrationIds}
 	buf, err := json.Marshal(params)
@@ -113,7 +203,10 @@ func (c DeviceClient) AliasUnbind(alias string, registrationIds []string) error
 		return err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("%s", string(resp.Bytes()))
+		return newAPIError(resp)
+	}
+	if c.Registry != nil {
+		c.Registry.recordAliasDelete(alias, registrationIds)
 	}
 	return nil
 }
@@ -129,7 +222,7 @@ func (c DeviceClient) TagsGet() (*Tags, error) {
 		return nil, err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return nil, errors.New(resp.Status())
+		return nil, newAPIError(resp)
 	}
 	var out Tags
 	err = json.Unmarshal(resp.Bytes(), &out)
@@ -144,13 +237,19 @@ type TagCheckResult struct {
 }
 
 func (c DeviceClient) TagCheck(tag, registrationId string) (bool, error) {
-	link := c.url + "/v3/tags/" + tag + "/registration_ids/" + registrationId
+	if err := ValidateTag(tag); err != nil {
+		return false, err
+	}
+	if err := validateRegistrationId(registrationId); err != nil {
+		return false, err
+	}
+	link := c.url + "/v3/tags/" + url.PathEscape(tag) + "/registration_ids/" + url.PathEscape(registrationId)
 	resp, err := c.Request("GET", link, nil, false)
 	if err != nil {
 		return false, err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return false, errors.New(resp.Status())
+		return false, newAPIError(resp)
 	}
 	var out TagCheckResult
 	err = json.Unmarshal(resp.Bytes(), &out)
@@ -165,8 +264,15 @@ type TagUpdatePayload struct {
 	Remove []string `json:"remove,omitempty"`
 }
 
+// TagUpdate adds and removes devices from tag, in chunks of
+// MaxTagRegistrationIds; see TagUpdateChunked for the outcome of each.
 func (c DeviceClient) TagUpdate(tag string, payload *TagUpdatePayload) error {
-	link := c.url + "/v3/tags/" + tag
+	_, err := c.TagUpdateChunked(tag, payload)
+	return err
+}
+
+func (c DeviceClient) tagUpdate(tag string, payload *TagUpdatePayload) error {
+	link := c.url + "/v3/tags/" + url.PathEscape(tag)
 	params := make(map[string]interface{})
 	params["registration_ids"] = payload
 	buf, err := json.Marshal(params)
@@ -178,13 +284,19 @@ func (c DeviceClient) TagUpdate(tag string, payload *TagUpdatePayload) error {
 		return err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return errors.New(resp.Status())
+		return newAPIError(resp)
+	}
+	if c.Registry != nil {
+		c.Registry.recordTagUpdate(tag, payload)
 	}
 	return nil
 }
 
 func (c DeviceClient) TagDelete(tag string, platforms []string) error {
-	link := c.url + "/v3/tags/" + tag
+	if err := ValidateTag(tag); err != nil {
+		return err
+	}
+	link := c.url + "/v3/tags/" + url.PathEscape(tag)
 	if len(platforms) > 0 {
 		link += "?platform=" + strings.Join(platforms, ",")
 	}
@@ -193,7 +305,10 @@ func (c DeviceClient) TagDelete(tag string, platforms []string) error {
 		return err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return errors.New(resp.Status())
+		return newAPIError(resp)
+	}
+	if c.Registry != nil && len(platforms) == 0 {
+		c.Registry.recordTagDelete(tag)
 	}
 	return nil
 }
@@ -0,0 +1,80 @@
+package jpush
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sustring/push/common"
+)
+
+func TestDeviceSettingJSON(t *testing.T) {
+	type field struct {
+		name  string
+		apply func(in *common.SetDeviceInput)
+		json  string // empty when the field must be absent
+	}
+	aliases := []field{
+		{"alias unchanged", func(in *common.SetDeviceInput) {}, ``},
+		{"alias set", func(in *common.SetDeviceInput) { in.Alias = "u1" }, `"alias":"u1"`},
+		{"alias cleared", func(in *common.SetDeviceInput) { in.CleanAlias = true }, `"alias":""`},
+		{"alias cleared over set", func(in *common.SetDeviceInput) { in.Alias, in.CleanAlias = "u1", true }, `"alias":""`},
+	}
+	mobiles := []field{
+		{"mobile unchanged", func(in *common.SetDeviceInput) {}, ``},
+		{"mobile set", func(in *common.SetDeviceInput) { in.Mobile = "138 0013 8000" }, `"mobile":"13800138000"`},
+		{"mobile cleared", func(in *common.SetDeviceInput) { in.CleanMobile = true }, `"mobile":""`},
+	}
+	tags := []field{
+		{"tags unchanged", func(in *common.SetDeviceInput) {}, ``},
+		{"tags added", func(in *common.SetDeviceInput) { in.AddTags = []string{"a", "b"} }, `"tags":{"add":["a","b"]}`},
+		{"tags removed", func(in *common.SetDeviceInput) { in.DelTags = []string{"c"} }, `"tags":{"remove":["c"]}`},
+		{"tags added and removed", func(in *common.SetDeviceInput) { in.AddTags, in.DelTags = []string{"a"}, []string{"c"} }, `"tags":{"add":["a"],"remove":["c"]}`},
+		{"tags cleared", func(in *common.SetDeviceInput) { in.CleanTags = true }, `"tags":""`},
+		{"tags cleared over added", func(in *common.SetDeviceInput) { in.CleanTags, in.AddTags = true, []string{"a"} }, `"tags":""`},
+	}
+
+	for _, a := range aliases {
+		for _, m := range mobiles {
+			for _, tg := range tags {
+				in := &common.SetDeviceInput{Id: "rid"}
+				var parts []string
+				for _, f := range []field{tg, a, m} {
+					f.apply(in)
+					if f.json != "" {
+						parts = append(parts, f.json)
+					}
+				}
+				want := "{" + strings.Join(parts, ",") + "}"
+
+				payload, err := deviceSetting(in)
+				if err != nil {
+					t.Fatal(err)
+				}
+				buf, err := json.Marshal(payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(buf) != want {
+					t.Errorf("%s, %s, %s: got %s, want %s", a.name, m.name, tg.name, buf, want)
+				}
+			}
+		}
+	}
+
+	if _, err := deviceSetting(&common.SetDeviceInput{Id: "rid", Mobile: "12345"}); err == nil {
+		t.Error("invalid mobile accepted")
+	}
+}
+
+func TestDeviceSettingBuilder(t *testing.T) {
+	payload := NewDeviceSetting().AddTags("a").ClearTags().AddTags("b").RemoveTags()
+	buf, _ := json.Marshal(payload)
+	if string(buf) != `{"tags":""}` {
+		t.Fatalf("tags added after ClearTags: %s", buf)
+	}
+	buf, _ = json.Marshal(NewDeviceSetting().RemoveTags())
+	if string(buf) != `{}` {
+		t.Fatalf("empty RemoveTags changed tags: %s", buf)
+	}
+}
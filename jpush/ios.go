@@ -0,0 +1,119 @@
+package jpush
+
+import "github.com/sustring/push/common"
+
+// IOSAlert is the APNs alert dictionary. NotificationIOS.Alert accepts
+// either a plain string or an *IOSAlert.
+type IOSAlert struct {
+	Title           string   `json:"title,omitempty"`
+	Subtitle        string   `json:"subtitle,omitempty"`
+	Body            string   `json:"body,omitempty"`
+	TitleLocKey     string   `json:"title-loc-key,omitempty"`
+	TitleLocArgs    []string `json:"title-loc-args,omitempty"`
+	SubtitleLocKey  string   `json:"subtitle-loc-key,omitempty"`
+	SubtitleLocArgs []string `json:"subtitle-loc-args,omitempty"`
+	LocKey          string   `json:"loc-key,omitempty"`
+	LocArgs         []string `json:"loc-args,omitempty"`
+	ActionLocKey    string   `json:"action-loc-key,omitempty"`
+	LaunchImage     string   `json:"launch-image,omitempty"`
+}
+
+// IOSSound is the critical alert sound dictionary. NotificationIOS.Sound
+// accepts either a sound file name or an *IOSSound.
+type IOSSound struct {
+	Critical int     `json:"critical"` // 1 for a critical alert
+	Name     string  `json:"name"`
+	Volume   float64 `json:"volume"` // 0.0 ~ 1.0
+}
+
+func CriticalSound(name string, volume float64) *IOSSound {
+	return &IOSSound{Critical: 1, Name: name, Volume: volume}
+}
+
+type InterruptionLevel string
+
+const (
+	InterruptionPassive       InterruptionLevel = "passive"
+	InterruptionActive        InterruptionLevel = "active"
+	InterruptionTimeSensitive InterruptionLevel = "time-sensitive"
+	InterruptionCritical      InterruptionLevel = "critical"
+)
+
+func NewIOSNotification(alert interface{}) *NotificationIOS {
+	return &NotificationIOS{Alert: alert}
+}
+
+func (n *NotificationIOS) WithSound(sound interface{}) *NotificationIOS {
+	n.Sound = sound
+	return n
+}
+
+func (n *NotificationIOS) WithInterruptionLevel(level InterruptionLevel) *NotificationIOS {
+	n.InterruptionLevel = level
+	return n
+}
+
+func (n *NotificationIOS) WithRelevanceScore(score float64) *NotificationIOS {
+	n.RelevanceScore = score
+	return n
+}
+
+// alertBody returns the text shown to the user, which is what gets shortened
+// when the payload is too large.
+func (n *NotificationIOS) alertBody() (string, func(string)) {
+	switch alert := n.Alert.(type) {
+	case string:
+		return alert, func(s string) { n.Alert = s }
+	case *IOSAlert:
+		if alert != nil {
+			return alert.Body, func(s string) { alert.Body = s }
+		}
+	}
+	return "", nil
+}
+
+const (
+	LiveActivityStart  = "start"
+	LiveActivityUpdate = "update"
+	LiveActivityEnd    = "end"
+)
+
+// LiveActivity updates an iOS live activity. It is sent instead of a
+// notification, with Audience.LiveActivityId selecting the activity.
+type LiveActivity struct {
+	IOS *LiveActivityIOS `json:"ios"`
+}
+
+type LiveActivityIOS struct {
+	Event          string                 `json:"event"` // start, update, end
+	ContentState   map[string]interface{} `json:"content-state"`
+	DismissalDate  int64                  `json:"dismissal-date,omitempty"` // unix seconds, for end
+	StaleDate      int64                  `json:"stale-date,omitempty"`
+	Alert          *IOSAlert              `json:"alert,omitempty"`
+	RelevanceScore float64                `json:"relevance-score,omitempty"`
+	AttributesType string                 `json:"attributes-type,omitempty"` // for start
+	Attributes     map[string]interface{} `json:"attributes,omitempty"`      // for start
+}
+
+func applyRichContentIOS(n *NotificationIOS, rich *common.RichContent, alert string) {
+	if rich == nil || rich.Title == "" {
+		return
+	}
+	n.Alert = &IOSAlert{Title: rich.Title, Body: alert}
+}
+
+func (c PushClient) PushLiveActivity(liveActivityId string, activity *LiveActivityIOS) (*PushResult, error) {
+	payload := liveActivityPayload(liveActivityId, activity)
+	if err := payload.Validate(); err != nil {
+		return nil, err
+	}
+	return c.Push(payload, false)
+}
+
+func liveActivityPayload(liveActivityId string, activity *LiveActivityIOS) *PushPayload {
+	return &PushPayload{
+		Platform:     NewPlatforms(PlatformIOS),
+		Audience:     &Audience{LiveActivityId: liveActivityId},
+		LiveActivity: &LiveActivity{IOS: activity},
+	}
+}
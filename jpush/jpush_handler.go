@@ -16,6 +16,14 @@ type Client struct {
 	*DeviceClient
 	*ReportClient
 	*ScheduleClient
+
+	// ChannelIds are the Xiaomi/OPPO channel ids registered per category.
+	ChannelIds map[common.NotificationCategory]VendorChannelIds
+	// LocaleTag names the tag marking devices of a locale, DefaultLocaleTag
+	// when nil.
+	LocaleTag func(locale string) string
+	// Aliases, when set, enforces its policy after SetDevice binds an alias.
+	Aliases *AliasManager
 }
 
 func NewClient(appKey, masterSecret, groupKey, groupMasterSecret string) *Client {
@@ -26,19 +34,19 @@ func NewClient(appKey, masterSecret, groupKey, groupMasterSecret string) *Client
 		GroupMasterSecret: groupMasterSecret,
 	}
 	return &Client{
-		&PushClient{
+		PushClient: &PushClient{
 			BaseClient: base,
 			url:        PushUrl,
 		},
-		&DeviceClient{
+		DeviceClient: &DeviceClient{
 			BaseClient: base,
 			url:        DeviceUrl,
 		},
-		&ReportClient{
+		ReportClient: &ReportClient{
 			BaseClient: base,
 			url:        ReportUrl,
 		},
-		&ScheduleClient{
+		ScheduleClient: &ScheduleClient{
 			BaseClient: nil,
 			url:        PushUrl,
 		},
@@ -46,38 +54,94 @@ func NewClient(appKey, masterSecret, groupKey, groupMasterSecret string) *Client
 }
 
 func (c Client) SetDevice(in *common.SetDeviceInput) (*common.SetDeviceOutput, error) {
-	payload := &DeviceSettingPayload{
-		Alias: in.Alias,
+	payload, err := deviceSetting(in)
+	if err != nil {
+		return nil, err
+	}
+
+	binding := c.Aliases != nil && in.Alias != "" && !in.CleanAlias
+	if binding {
+		// JPush refuses to bind a device over its cap, so room is made first
+		if _, err := c.Aliases.MakeRoom(in.Alias, in.Id); err != nil {
+			return nil, err
+		}
+	}
+	err = c.DeviceSet(in.Id, payload)
+	if err != nil {
+		return nil, err
+	}
+	// the policy is enforced only once bound, so a failed bind displaces at
+	// most the one device making room
+	if binding {
+		if _, err := c.Aliases.Enforce(in.Alias, in.Id); err != nil {
+			return nil, err
+		}
+	}
+	return &common.SetDeviceOutput{}, nil
+}
+
+// deviceSetting maps in to a payload carrying only the fields to change.
+func deviceSetting(in *common.SetDeviceInput) (*DeviceSettingPayload, error) {
+	payload := NewDeviceSetting()
+	if in.CleanAlias {
+		payload.ClearAlias()
+	} else if in.Alias != "" {
+		payload.SetAlias(in.Alias)
+	}
+	if in.CleanMobile {
+		payload.ClearMobile()
+	} else if in.Mobile != "" {
+		mobile, err := NormalizeMobile(in.Mobile)
+		if err != nil {
+			return nil, err
+		}
+		payload.SetMobile(mobile)
 	}
 	if in.CleanTags {
-		payload.Tags = ""
+		payload.ClearTags()
 	} else {
-		payload.Tags = &DeviceSettingRequestTags{
-			Add:    in.AddTags,
-			Remove: in.DelTags,
-		}
+		payload.AddTags(in.AddTags...).RemoveTags(in.DelTags...)
 	}
+	return payload, nil
+}
 
-	err := c.DeviceSet(in.Id, payload)
+func (c Client) BindMobile(in *common.BindMobileInput) (*common.BindMobileOutput, error) {
+	err := c.MobileSet(in.Id, in.Mobile)
 	if err != nil {
 		return nil, err
 	}
-	return &common.SetDeviceOutput{}, nil
+	return &common.BindMobileOutput{}, nil
 }
 
 func (c Client) GetDevice(in *common.GetDeviceInput) (*common.GetDeviceOutput, error) {
-	_, err := c.DeviceView(in.Id)
+	device, err := c.DeviceView(in.Id)
 	if err != nil {
 		return nil, err
 	}
 
 	return &common.GetDeviceOutput{
-		Id:      "",
-		Alias:   "",
-		TagList: nil,
+		Id:      in.Id,
+		Alias:   device.Alias,
+		TagList: device.Tags,
+		Mobile:  device.Mobile,
 	}, nil
 }
 
+func (c Client) GetDeviceStatus(in *common.GetDeviceStatusInput) (*common.GetDeviceStatusOutput, error) {
+	result, err := c.DeviceStatus(in.Ids)
+	if err != nil {
+		return nil, err
+	}
+	out := &common.GetDeviceStatusOutput{Devices: make(map[string]*common.DeviceStatus, len(result))}
+	for id, r := range result {
+		if r == nil {
+			continue
+		}
+		out.Devices[id] = &common.DeviceStatus{Online: r.Online, LastOnline: r.LastOnline}
+	}
+	return out, nil
+}
+
 func (c Client) UpdateTag(in *common.UpdateTagInput) (*common.UpdateTagOutput, error) {
 	payload := &TagUpdatePayload{
 		Add:    in.AddList,
@@ -107,7 +171,62 @@ func (c Client) CheckTag(in *common.CheckTagInput) (*common.CheckTagOutput, erro
 	return &common.CheckTagOutput{}, nil
 }
 
+// PushMessage sends in, as several pushes when it is localized or its
+// audience is too large for one. When a push fails the msg ids of those
+// already sent are returned with the error.
 func (c Client) PushMessage(in *common.PushMessageInput) (*common.PushMessageOutput, error) {
+	if in.AlertLocKey != "" || len(in.AlertLocArgs) > 0 {
+		if in.AlertLocKey == "" || in.Platform != common.IOS {
+			return nil, errors.New("alert loc-key and loc-args need a loc-key and an iOS-only push")
+		}
+		return c.pushLocKey(in)
+	}
+	if len(in.Locales) > 0 {
+		return c.pushLocalized(in)
+	}
+
+	payload, truncated, err := c.buildPushPayload(in)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := c.PushChunked(payload)
+	out := &common.PushMessageOutput{Truncated: truncated}
+	addPushResults(out, list)
+	if err != nil {
+		return partialOutput(out), err
+	}
+	return out, nil
+}
+
+func addPushResults(out *common.PushMessageOutput, list []*PushResult) {
+	for _, res := range list {
+		out.MsgIds = append(out.MsgIds, string(res.MsgId))
+	}
+	if out.MsgId == "" && len(out.MsgIds) > 0 {
+		out.MsgId = out.MsgIds[0]
+	}
+}
+
+func quickAppNotification(in *common.PushMessageInput, extra map[string]interface{}) *NotificationQuickApp {
+	title := in.QuickApp.Title
+	if title == "" && in.Rich != nil {
+		title = in.Rich.Title
+	}
+	return &NotificationQuickApp{Alert: in.Alert, Title: title, Page: in.QuickApp.Page, Extras: extra}
+}
+
+// partialOutput is what a failed push returns: the msg ids of the chunks
+// sent before the failure, so a retry does not send them twice, or nil when
+// nothing was sent.
+func partialOutput(out *common.PushMessageOutput) *common.PushMessageOutput {
+	if len(out.MsgIds) == 0 {
+		return nil
+	}
+	return out
+}
+
+func (c Client) buildPushPayload(in *common.PushMessageInput) (*PushPayload, bool, error) {
 	payload := &PushPayload{}
 
 	payload.Audience = &Audience{}
@@ -123,7 +242,9 @@ func (c Client) PushMessage(in *common.PushMessageInput) (*common.PushMessageOut
 	}
 
 	extra := map[string]interface{}{"msg_id": in.Id, "msg_type": in.Type}
-	if !in.Presentation {
+	if in.InApp != nil {
+		payload.Message = toMessage(in.InApp, extra)
+	} else if !in.Presentation {
 		payload.Message = &Message{
 			MsgContent: in.Alert,
 			//Title:       in.Alert,
@@ -132,8 +253,15 @@ func (c Client) PushMessage(in *common.PushMessageInput) (*common.PushMessageOut
 		}
 	}
 
+	if !in.Platform.Valid() {
+		return nil, false, errors.New("invalid input params")
+	}
+	payload.Platform = toPlatforms(in.Platform)
+	hmosCategory := in.HmosCategory
+	if hmosCategory == "" {
+		hmosCategory = HmosCategory(in.Category)
+	}
 	if in.Platform == common.ALL {
-		payload.Platform = PlatformAll
 		if in.Presentation {
 			payload.Notification = &Notification{
 				Alert: in.Alert,
@@ -147,44 +275,137 @@ func (c Client) PushMessage(in *common.PushMessageInput) (*common.PushMessageOut
 					Extras:   extra,
 				},
 			}
+			if hmosCategory != "" {
+				payload.Notification.Hmos = &NotificationHmos{
+					Alert:    in.Alert,
+					Category: hmosCategory,
+					Extras:   extra,
+				}
+			}
+			if in.QuickApp != nil {
+				payload.Notification.QuickApp = quickAppNotification(in, extra)
+			}
 		}
-	} else if in.Platform == common.Android {
-		payload.Platform = PlatformAndroid
-		if in.Presentation {
-			payload.Notification = &Notification{
-				Android: &NotificationAndroid{
-					Alert:  in.Alert,
-					Extras: extra,
-				},
+	} else if in.Presentation {
+		payload.Notification = &Notification{}
+		if in.Platform.Has(common.Android) {
+			payload.Notification.Android = &NotificationAndroid{
+				Alert:  in.Alert,
+				Extras: extra,
 			}
 		}
-	} else if in.Platform == common.IOS {
-		payload.Platform = PlatformIOS
-		if in.Presentation {
-			payload.Notification = &Notification{
-				IOS: &NotificationIOS{
-					Alert:    in.Alert,
-					ThreadId: in.Type,
-					Extras:   extra,
-				},
+		if in.Platform.Has(common.IOS) {
+			payload.Notification.IOS = &NotificationIOS{
+				Alert:    in.Alert,
+				ThreadId: in.Type,
+				Extras:   extra,
+			}
+		}
+		if in.Platform.Has(common.Hmos) {
+			if hmosCategory == "" {
+				return nil, false, ValidationError{Field: "notification.hmos.category", Message: "is required"}
+			}
+			payload.Notification.Hmos = &NotificationHmos{
+				Alert:    in.Alert,
+				Category: hmosCategory,
+				Extras:   extra,
+			}
+		}
+		if in.Platform.Has(common.QuickApp) {
+			if in.QuickApp == nil {
+				return nil, false, ValidationError{Field: "notification.quickapp", Message: "is required"}
+			}
+			payload.Notification.QuickApp = quickAppNotification(in, extra)
+			if err := payload.Notification.QuickApp.validate(); err != nil {
+				return nil, false, err
 			}
 		}
-	} else {
-		return nil, errors.New("invalid input params")
 	}
 
-	_, err := c.Push(payload, false)
-	if err != nil {
-		return nil, err
+	if in.Sms != nil {
+		payload.SmsMessage = &SmsMessage{
+			TempId:       in.Sms.TemplateId,
+			TempPara:     in.Sms.TemplateParams,
+			DelayTime:    in.Sms.DelaySeconds,
+			ActiveFilter: in.Sms.ActiveFilter,
+		}
+	}
+
+	if payload.Notification != nil && payload.Notification.Android != nil {
+		applyRichContent(payload.Notification.Android, in.Rich)
+	}
+	if payload.Notification != nil && payload.Notification.IOS != nil {
+		applyRichContentIOS(payload.Notification.IOS, in.Rich, in.Alert)
+	}
+
+	if option := CategoryChannelOption(in.Category, c.ChannelIds[in.Category]); !option.IsEmpty() {
+		payload.Options = &PushOptions{
+			ApnsProduction:    true, // JPush's default when options are omitted
+			ThirdPartyChannel: option,
+		}
 	}
 
-	return &common.PushMessageOutput{}, nil
+	truncated := false
+	if in.TruncateAlert {
+		var err error
+		if truncated, err = payload.TruncateAlert(); err != nil {
+			return nil, false, err
+		}
+	}
+	return payload, truncated, nil
 }
 
 func (c Client) InspectMessage(in *common.InspectMessageInput) (*common.InspectMessageOutput, error) {
-	_, err := c.ReceivedDetail(in.MsgId)
+	list, err := c.ReceivedDetail(in.MsgId)
 	if err != nil {
 		return nil, err
 	}
-	return &common.InspectMessageOutput{}, nil
+	if len(list) == 0 {
+		return &common.InspectMessageOutput{}, nil
+	}
+	return &common.InspectMessageOutput{
+		MsgId:           string(list[0].MsgId),
+		AndroidReceived: list[0].JPushReceived,
+		IOSAPNSReceived: list[0].IOSAPNSReceived,
+		IOSAPNSSent:     list[0].IOSAPNSSent,
+		IOSMsgReceived:  list[0].IOSMsgReceived,
+	}, nil
+}
+
+// toMessage maps the in-app payload to a JPush custom message. Its extras
+// are merged over the msg_id/msg_type pair every push carries.
+func toMessage(in *common.InAppMessage, extra map[string]interface{}) *Message {
+	extras := make(map[string]interface{}, len(extra)+len(in.Extras))
+	for k, v := range extra {
+		extras[k] = v
+	}
+	for k, v := range in.Extras {
+		extras[k] = v
+	}
+	return &Message{
+		MsgContent:  in.Content,
+		Title:       in.Title,
+		ContentType: in.ContentType,
+		Extras:      extras,
+	}
+}
+
+func toPlatforms(t common.PlatformType) Platforms {
+	if t == common.ALL {
+		return NewPlatforms(PlatformAll)
+	}
+	var list Platforms
+	if t.Has(common.Android) {
+		list = append(list, PlatformAndroid)
+	}
+	if t.Has(common.IOS) {
+		list = append(list, PlatformIOS)
+	}
+	if t.Has(common.QuickApp) {
+		list = append(list, PlatformQuickApp)
+	}
+	if t.Has(common.Hmos) {
+		list = append(list, PlatformHmos)
+	}
+	return list
 }
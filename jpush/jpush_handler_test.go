@@ -1,8 +1,11 @@
 package jpush
 
 import (
+	"encoding/json"
 	"os"
 	"testing"
+
+	"github.com/sustring/push/common"
 )
 
 func TestMain(m *testing.M) {
@@ -48,7 +51,7 @@ func TestPushRegistrationId(t *testing.T) {
 	client := NewClient(AndroidAppKey, AndroidMasterSecret, "", "")
 	res, err := client.Push(&PushPayload{
 		Cid:          "60823c3e0d364f99832722ad-eb56f386-79ef-4036-85cc-4bdaf6c1dbcc",
-		Platform:     PlatformAndroid,
+		Platform:     NewPlatforms(PlatformAndroid),
 		Audience:     &audience,
 		Notification: &notification,
 	}, false)
@@ -76,7 +79,7 @@ func TestPushAlias(t *testing.T) {
 
 	client := NewClient(AndroidAppKey, AndroidMasterSecret, "", "")
 	res, err := client.Push(&PushPayload{
-		Platform:     PlatformAndroid,
+		Platform:     NewPlatforms(PlatformAndroid),
 		Audience:     &audience,
 		Notification: &notification,
 	}, false)
@@ -104,7 +107,7 @@ func TestPushTag(t *testing.T) {
 
 	client := NewClient(AndroidAppKey, AndroidMasterSecret, "", "")
 	res, err := client.Push(&PushPayload{
-		Platform:     PlatformAndroid,
+		Platform:     NewPlatforms(PlatformAndroid),
 		Audience:     &audience,
 		Notification: &notification,
 	}, false)
@@ -134,13 +137,7 @@ func TestClientDeviceView(t *testing.T) {
 }
 
 func TestClientDeviceRequest(t *testing.T) {
-	tags := &DeviceSettingRequestTags{
-		Add: []string{"mobile"},
-	}
-	req := &DeviceSettingPayload{
-		Alias: "qiuqiankun",
-		Tags:  tags,
-	}
+	req := NewDeviceSetting().SetAlias("qiuqiankun").AddTags("mobile")
 	err := client.DeviceSet(AndroidRegistrationId, req)
 	if err != nil {
 		t.Error(err)
@@ -201,3 +198,90 @@ func TestClientDeviceDeleteTag(t *testing.T) {
 		return
 	}
 }
+
+func TestBuildPushPayloadInApp(t *testing.T) {
+	payload, _, err := client.buildPushPayload(&common.PushMessageInput{
+		Platform:     common.Android,
+		Id:           123,
+		Type:         "order",
+		Alert:        "your order shipped",
+		Audience:     common.AudienceInfo{AliasList: []string{"qiuqiankun"}},
+		Presentation: true,
+		InApp: &common.InAppMessage{
+			Title:       "Order shipped",
+			Content:     `{"order_id":42}`,
+			ContentType: "application/json",
+			Extras:      map[string]interface{}{"order_id": 42},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := payload.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Notification == nil || payload.Notification.Android.Alert != "your order shipped" {
+		t.Fatalf("missing notification: %+v", payload.Notification)
+	}
+	m := payload.Message
+	if m == nil || m.Title != "Order shipped" || m.ContentType != "application/json" {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+	if m.Extras["msg_id"] != int64(123) || m.Extras["order_id"] != 42 {
+		t.Fatalf("unexpected extras: %+v", m.Extras)
+	}
+}
+
+func TestBuildPushPayloadHmosCategory(t *testing.T) {
+	in := &common.PushMessageInput{Platform: common.Android | common.Hmos, Presentation: true, Alert: "hi"}
+	if _, _, err := client.buildPushPayload(in); err == nil {
+		t.Fatal("expected an error for hmos without a category")
+	}
+	in.HmosCategory = "IM"
+	payload, _, err := client.buildPushPayload(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Notification.Hmos.Category != "IM" {
+		t.Fatalf("unexpected hmos notification %+v", payload.Notification.Hmos)
+	}
+	// without a category, pushes to all platforms leave hmos out
+	payload, _, err = client.buildPushPayload(&common.PushMessageInput{Presentation: true, Alert: "hi"})
+	if err != nil || payload.Notification.Hmos != nil {
+		t.Fatalf("unexpected hmos notification %+v, %v", payload.Notification.Hmos, err)
+	}
+}
+
+func TestBuildPushPayloadQuickApp(t *testing.T) {
+	in := &common.PushMessageInput{
+		Platform:     common.QuickApp,
+		Audience:     common.AudienceInfo{AliasList: []string{"u1"}},
+		Presentation: true,
+		Alert:        "hi",
+	}
+	if _, _, err := client.buildPushPayload(in); err == nil {
+		t.Fatal("expected an error for quickapp without title and page")
+	}
+	in.QuickApp = &common.QuickAppContent{Page: "/page1"}
+	if _, _, err := client.buildPushPayload(in); err == nil {
+		t.Fatal("expected an error for quickapp without a title")
+	}
+	in.Rich = &common.RichContent{Title: "news"}
+	payload, _, err := client.buildPushPayload(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(payload.Notification)
+	want := `{"quickapp":{"alert":"hi","title":"news","page":"/page1","extras":{"msg_id":0,"msg_type":""}}}`
+	if string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+	if err := payload.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	// without quickapp content, pushes to all platforms leave it out
+	payload, _, err = client.buildPushPayload(&common.PushMessageInput{Presentation: true, Alert: "hi"})
+	if err != nil || payload.Notification.QuickApp != nil {
+		t.Fatalf("unexpected quickapp notification %+v, %v", payload.Notification.QuickApp, err)
+	}
+}
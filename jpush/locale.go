@@ -0,0 +1,105 @@
+package jpush
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sustring/push/common"
+)
+
+// DefaultLocaleTag turns "zh-CN" into "lang_zh_CN". JPush tags do not allow
+// '-', so it is replaced with '_'.
+func DefaultLocaleTag(locale string) string {
+	return "lang_" + strings.Replace(locale, "-", "_", -1)
+}
+
+func (c Client) localeTag(locale string) string {
+	if c.LocaleTag != nil {
+		return c.LocaleTag(locale)
+	}
+	return DefaultLocaleTag(locale)
+}
+
+// pushLocalized sends one push per locale, each limited to the devices
+// carrying that locale's tag, and one with the unlocalized alert, when there
+// is one, to the devices carrying none of them.
+func (c Client) pushLocalized(in *common.PushMessageInput) (*common.PushMessageOutput, error) {
+	locales := make([]string, 0, len(in.Locales))
+	for locale := range in.Locales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	out := &common.PushMessageOutput{}
+	var tags []string
+	for _, locale := range locales {
+		content := in.Locales[locale]
+		localized := *in
+		localized.Locales = nil
+		localized.Alert = content.Alert
+		if content.Title != "" {
+			rich := common.RichContent{}
+			if in.Rich != nil {
+				rich = *in.Rich
+			}
+			rich.Title = content.Title
+			localized.Rich = &rich
+		}
+
+		tag := c.localeTag(locale)
+		tags = append(tags, tag)
+		if err := c.pushAudience(&localized, out, func(a *Audience) { a.TagAnd = append(a.TagAnd, tag) }); err != nil {
+			return partialOutput(out), err
+		}
+	}
+
+	if in.Alert == "" {
+		return out, nil
+	}
+	fallback := *in
+	fallback.Locales = nil
+	if err := c.pushAudience(&fallback, out, func(a *Audience) { a.TagNot = append(a.TagNot, tags...) }); err != nil {
+		return partialOutput(out), err
+	}
+	return out, nil
+}
+
+// pushAudience builds the push for in, narrows its audience with narrow and
+// adds the msg ids sent to out, also when a chunk failed.
+func (c Client) pushAudience(in *common.PushMessageInput, out *common.PushMessageOutput, narrow func(a *Audience)) error {
+	payload, truncated, err := c.buildPushPayload(in)
+	if err != nil {
+		return err
+	}
+	narrow(payload.Audience)
+	list, err := c.PushChunked(payload)
+	addPushResults(out, list)
+	out.Truncated = out.Truncated || truncated
+	return err
+}
+
+// pushLocKey sends an iOS-only message once, to be localized on the device
+// from the app's strings file; Locales are not used.
+func (c Client) pushLocKey(in *common.PushMessageInput) (*common.PushMessageOutput, error) {
+	localized := *in
+	localized.Locales = nil
+	payload, truncated, err := c.buildPushPayload(&localized)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Notification != nil && payload.Notification.IOS != nil {
+		payload.Notification.IOS.Alert = &IOSAlert{
+			Body:    in.Alert,
+			LocKey:  in.AlertLocKey,
+			LocArgs: in.AlertLocArgs,
+		}
+	}
+
+	list, err := c.PushChunked(payload)
+	out := &common.PushMessageOutput{Truncated: truncated}
+	addPushResults(out, list)
+	if err != nil {
+		return partialOutput(out), err
+	}
+	return out, nil
+}
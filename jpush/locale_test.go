@@ -0,0 +1,160 @@
+package jpush
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sustring/push/common"
+)
+
+// pushServer answers pushes with increasing msg ids, failing the push
+// numbered failAt (from 1; 0 never fails).
+func pushServer(t *testing.T, failAt int) (*httptest.Server, *[]*PushPayload) {
+	var payloads []*PushPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p PushPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		payloads = append(payloads, &p)
+		if len(payloads) == failAt {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"sendno":"0","msg_id":"%d"}`, len(payloads))
+	}))
+	return server, &payloads
+}
+
+func TestPushLocalizedFallback(t *testing.T) {
+	server, payloads := pushServer(t, 0)
+	defer server.Close()
+	c := Client{PushClient: &PushClient{BaseClient: &BaseClient{}, url: server.URL}}
+
+	out, err := c.PushMessage(&common.PushMessageInput{
+		Platform:     common.Android,
+		Presentation: true,
+		Alert:        "hello",
+		Audience:     common.AudienceInfo{TagList: []string{"vip"}},
+		Locales: map[string]common.LocalizedContent{
+			"zh-CN": {Alert: "你好"},
+			"de":    {Alert: "hallo"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(out.MsgIds, ",") != "1,2,3" || out.MsgId != "1" {
+		t.Fatalf("unexpected output %+v", out)
+	}
+	fallback := (*payloads)[2]
+	if strings.Join(fallback.Audience.TagNot, ",") != "lang_de,lang_zh_CN" || strings.Join(fallback.Audience.Tag, ",") != "vip" || len(fallback.Audience.TagAnd) != 0 {
+		t.Fatalf("unexpected fallback audience %+v", fallback.Audience)
+	}
+	if fallback.Notification.Android.Alert != "hello" {
+		t.Fatalf("unexpected fallback alert %q", fallback.Notification.Android.Alert)
+	}
+}
+
+func TestPushLocalizedWithoutFallback(t *testing.T) {
+	server, payloads := pushServer(t, 0)
+	defer server.Close()
+	c := Client{PushClient: &PushClient{BaseClient: &BaseClient{}, url: server.URL}}
+
+	out, err := c.PushMessage(&common.PushMessageInput{
+		Platform:     common.Android,
+		Presentation: true,
+		Locales:      map[string]common.LocalizedContent{"de": {Alert: "hallo"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*payloads) != 1 || strings.Join(out.MsgIds, ",") != "1" || len((*payloads)[0].Audience.TagNot) != 0 {
+		t.Fatalf("expected only the locale push, got %d pushes", len(*payloads))
+	}
+}
+
+func TestPushMessagePartialFailure(t *testing.T) {
+	server, _ := pushServer(t, 2)
+	defer server.Close()
+	c := Client{PushClient: &PushClient{BaseClient: &BaseClient{}, url: server.URL}}
+
+	out, err := c.PushMessage(&common.PushMessageInput{
+		Platform:     common.Android,
+		Presentation: true,
+		Alert:        "hello",
+		Locales:      map[string]common.LocalizedContent{"de": {Alert: "hallo"}, "en": {Alert: "hello"}},
+	})
+	if err == nil || out == nil || strings.Join(out.MsgIds, ",") != "1" {
+		t.Fatalf("expected the first msg id with the error, got %+v, %v", out, err)
+	}
+
+	aliases := make([]string, MaxAudienceAliases+1)
+	for i := range aliases {
+		aliases[i] = fmt.Sprintf("u%d", i)
+	}
+	server, _ = pushServer(t, 2)
+	defer server.Close()
+	c.PushClient.url = server.URL
+	out, err = c.PushMessage(&common.PushMessageInput{
+		Platform:     common.Android,
+		Presentation: true,
+		Alert:        "hello",
+		Audience:     common.AudienceInfo{AliasList: aliases},
+	})
+	if err == nil || out == nil || strings.Join(out.MsgIds, ",") != "1" {
+		t.Fatalf("expected the first chunk's msg id with the error, got %+v, %v", out, err)
+	}
+
+	server, _ = pushServer(t, 1)
+	defer server.Close()
+	c.PushClient.url = server.URL
+	if out, err := c.PushMessage(&common.PushMessageInput{Platform: common.Android, Presentation: true, Alert: "hello"}); err == nil || out != nil {
+		t.Fatalf("expected no output when nothing was sent, got %+v", out)
+	}
+}
+
+func TestPushLocKeyRouting(t *testing.T) {
+	server, payloads := pushServer(t, 0)
+	defer server.Close()
+	c := Client{PushClient: &PushClient{BaseClient: &BaseClient{}, url: server.URL}}
+
+	for _, locales := range []map[string]common.LocalizedContent{nil, {"de": {Alert: "hallo"}}} {
+		*payloads = nil
+		out, err := c.PushMessage(&common.PushMessageInput{
+			Platform:     common.IOS,
+			Presentation: true,
+			Alert:        "hello",
+			AlertLocKey:  "GREETING",
+			AlertLocArgs: []string{"Ann"},
+			Locales:      locales,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*payloads) != 1 || out.MsgId != "1" {
+			t.Fatalf("expected one push, got %d", len(*payloads))
+		}
+		alert, _ := json.Marshal((*payloads)[0].Notification.IOS.Alert)
+		if string(alert) != `{"body":"hello","loc-args":["Ann"],"loc-key":"GREETING"}` {
+			t.Fatalf("unexpected alert %s", alert)
+		}
+	}
+
+	*payloads = nil
+	for _, in := range []*common.PushMessageInput{
+		{Platform: common.Android | common.IOS, Presentation: true, Alert: "hello", AlertLocKey: "GREETING"},
+		{Platform: common.IOS, Presentation: true, Alert: "hello", AlertLocArgs: []string{"Ann"}},
+	} {
+		if _, err := c.PushMessage(in); err == nil {
+			t.Errorf("expected an error for %+v", in)
+		}
+	}
+	if len(*payloads) != 0 {
+		t.Fatalf("rejected pushes were sent")
+	}
+}
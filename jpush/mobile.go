@@ -0,0 +1,42 @@
+package jpush
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	e164Mobile = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	cnMobile   = regexp.MustCompile(`^1[3-9][0-9]{9}$`)
+)
+
+// NormalizeMobile strips the spaces, dashes and parentheses people write
+// numbers with, and checks the result is either in E.164 format, e.g.
+// +8613800138000, or a mainland China mobile number, e.g. 13800138000.
+func NormalizeMobile(mobile string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')':
+			return -1
+		}
+		return r
+	}, mobile)
+	if !e164Mobile.MatchString(normalized) && !cnMobile.MatchString(normalized) {
+		return "", fmt.Errorf("invalid mobile number %q", mobile)
+	}
+	return normalized, nil
+}
+
+// MobileSet binds mobile to the device, leaving its alias and tags alone.
+// An empty mobile unbinds the current number.
+func (c DeviceClient) MobileSet(registrationId, mobile string) error {
+	if mobile != "" {
+		normalized, err := NormalizeMobile(mobile)
+		if err != nil {
+			return err
+		}
+		mobile = normalized
+	}
+	return c.DeviceSet(registrationId, NewDeviceSetting().SetMobile(mobile))
+}
@@ -0,0 +1,76 @@
+package jpush
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sustring/push/common"
+)
+
+func TestNormalizeMobile(t *testing.T) {
+	valid := map[string]string{
+		"13800138000":       "13800138000",
+		"138-0013-8000":     "13800138000",
+		"+86 138 0013 8000": "+8613800138000",
+		"+1 (415) 555-2671": "+14155552671",
+	}
+	for in, want := range valid {
+		got, err := NormalizeMobile(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeMobile(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "12800138000", "1380013800", "+0123456789", "8613800138000x", "+12345"} {
+		if _, err := NormalizeMobile(in); err == nil {
+			t.Errorf("NormalizeMobile(%q) accepted", in)
+		}
+	}
+}
+
+func TestBindMobile(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(buf))
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	registry := NewRegistry(NewMemoryRegistryStore())
+	c := Client{DeviceClient: &DeviceClient{BaseClient: &BaseClient{}, url: server.URL, Registry: registry}}
+	if _, err := c.BindMobile(&common.BindMobileInput{Id: "rid", Mobile: "138 0013 8000"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.BindMobile(&common.BindMobileInput{Id: "rid", Mobile: "12345"}); err == nil {
+		t.Fatal("invalid number accepted")
+	}
+	// a device update without a number keeps the bound one
+	if _, err := c.SetDevice(&common.SetDeviceInput{Id: "rid", Alias: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := registry.Get("rid"); e.Mobile != "13800138000" {
+		t.Fatalf("mobile lost: %+v", e)
+	}
+	if _, err := c.BindMobile(&common.BindMobileInput{Id: "rid"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`{"mobile":"13800138000"}`,
+		`{"alias":"u1"}`,
+		`{"mobile":""}`,
+	}
+	if len(bodies) != len(want) {
+		t.Fatalf("unexpected requests %q", bodies)
+	}
+	for i := range want {
+		if bodies[i] != want[i] {
+			t.Errorf("request %d = %s, want %s", i, bodies[i], want[i])
+		}
+	}
+	if e, _ := registry.Get("rid"); e.Mobile != "" {
+		t.Fatalf("mobile not unbound: %+v", e)
+	}
+}
@@ -0,0 +1,134 @@
+package jpush
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNameBytes is the longest tag or alias JPush accepts, in UTF-8 bytes.
+const MaxNameBytes = 40
+
+// nameSymbols are the punctuation JPush allows in tags and aliases besides
+// letters, digits and Chinese characters.
+const nameSymbols = "_!@#$&*+=.|￥"
+
+func validNameRune(r rune) bool {
+	return r < utf8.RuneSelf && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') ||
+		strings.ContainsRune(nameSymbols, r) ||
+		unicode.Is(unicode.Han, r)
+}
+
+func validateName(field, name string) error {
+	if name == "" {
+		return ValidationError{Field: field, Message: "is empty"}
+	}
+	if len(name) > MaxNameBytes {
+		return ValidationError{Field: field, Message: fmt.Sprintf("%q is %d bytes, max %d", name, len(name), MaxNameBytes)}
+	}
+	for _, r := range name {
+		if !validNameRune(r) {
+			return ValidationError{Field: field, Message: fmt.Sprintf("%q contains %q", name, r)}
+		}
+	}
+	return nil
+}
+
+// ValidateTag checks tag against JPush's rules: at most MaxNameBytes of
+// letters, digits, Chinese characters and _!@#$&*+=.|￥.
+func ValidateTag(tag string) error {
+	return validateName("tag", tag)
+}
+
+// ValidateAlias checks alias against the same rules as ValidateTag.
+func ValidateAlias(alias string) error {
+	return validateName("alias", alias)
+}
+
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if err := ValidateTag(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRegistrationId(registrationId string) error {
+	if registrationId == "" {
+		return ValidationError{Field: "registration_id", Message: "is empty"}
+	}
+	return nil
+}
+
+func (p *DeviceSettingPayload) validate() error {
+	if p.Alias != nil && *p.Alias != "" {
+		if err := ValidateAlias(*p.Alias); err != nil {
+			return err
+		}
+	}
+	if tags, ok := p.Tags.(*DeviceSettingRequestTags); ok && tags != nil {
+		if err := validateTags(tags.Add); err != nil {
+			return err
+		}
+		if err := validateTags(tags.Remove); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nameEscape starts an escaped byte in EscapeName's output.
+const nameEscape = '*'
+
+// EscapeName turns an arbitrary id, e.g. a user or group id, into a valid
+// tag or alias. Characters JPush does not allow, and '*' itself, are
+// replaced by '*' and the two hex digits of each of their UTF-8 bytes, so
+// "team-42" becomes "team*2D42". UnescapeName reverses it. It fails when the
+// result is longer than MaxNameBytes.
+func EscapeName(id string) (string, error) {
+	if id == "" {
+		return "", errors.New("cannot escape an empty id")
+	}
+	var b strings.Builder
+	for i := 0; i < len(id); {
+		r, size := utf8.DecodeRuneInString(id[i:])
+		if r != nameEscape && validNameRune(r) {
+			b.WriteString(id[i : i+size])
+		} else {
+			for _, c := range []byte(id[i : i+size]) {
+				fmt.Fprintf(&b, "%c%02X", nameEscape, c)
+			}
+		}
+		i += size
+	}
+	name := b.String()
+	if len(name) > MaxNameBytes {
+		return "", fmt.Errorf("escaped id %q is %d bytes, max %d", name, len(name), MaxNameBytes)
+	}
+	return name, nil
+}
+
+// UnescapeName returns the id EscapeName turned into name.
+func UnescapeName(name string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != nameEscape {
+			b.WriteByte(name[i])
+			continue
+		}
+		if i+2 >= len(name) {
+			return "", fmt.Errorf("truncated escape in %q", name)
+		}
+		c, err := strconv.ParseUint(name[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape %q in %q", name[i:i+3], name)
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,103 @@
+package jpush
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateTag(t *testing.T) {
+	valid := []string{"vip", "lang_zh_CN", "a!@#$&*+=.|￥", "会员等级3", strings.Repeat("x", 40), strings.Repeat("标", 13)}
+	for _, tag := range valid {
+		if err := ValidateTag(tag); err != nil {
+			t.Errorf("ValidateTag(%q): %v", tag, err)
+		}
+	}
+	invalid := []string{"", "a b", "a/b", "a-b", "a%20", "über", strings.Repeat("x", 41), strings.Repeat("标", 14)}
+	for _, tag := range invalid {
+		if err := ValidateTag(tag); err == nil {
+			t.Errorf("ValidateTag(%q) accepted", tag)
+		}
+	}
+	if err := ValidateAlias("user 1"); err == nil || !strings.HasPrefix(err.Error(), "alias: ") {
+		t.Errorf("unexpected alias error %v", err)
+	}
+}
+
+func TestEscapeName(t *testing.T) {
+	cases := map[string]string{
+		"team-42":    "team*2D42",
+		"a*b":        "a*2Ab",
+		"user_1":     "user_1",
+		"组/1":        "组*2F1",
+		"a b":        "a*20b",
+		"\xff":       "*FF",
+		"é":          "*C3*A9",
+		"x@mail.com": "x@mail.com",
+	}
+	for id, want := range cases {
+		name, err := EscapeName(id)
+		if err != nil || name != want {
+			t.Errorf("EscapeName(%q) = %q, %v; want %q", id, name, err, want)
+			continue
+		}
+		if err := ValidateTag(name); err != nil {
+			t.Errorf("EscapeName(%q) is not a valid tag: %v", id, err)
+		}
+		back, err := UnescapeName(name)
+		if err != nil || back != id {
+			t.Errorf("UnescapeName(%q) = %q, %v; want %q", name, back, err, id)
+		}
+	}
+	if _, err := EscapeName(strings.Repeat("-", 14)); err == nil {
+		t.Error("expected an error for an escaped id over 40 bytes")
+	}
+	for _, name := range []string{"a*2", "a*ZZ", "*+1"} {
+		if _, err := UnescapeName(name); err == nil {
+			t.Errorf("UnescapeName(%q) accepted", name)
+		}
+	}
+}
+
+func TestDeviceClientEscapesPaths(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Write([]byte(`{"result":true}`))
+	}))
+	defer server.Close()
+
+	c := DeviceClient{BaseClient: &BaseClient{}, url: server.URL}
+	if _, err := c.TagCheck("a#1|￥", "1a0018970a5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AliasDelete("用户"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/v3/tags/a%231%7C%EF%BF%A5/registration_ids/1a0018970a5",
+		"/v3/aliases/%E7%94%A8%E6%88%B7",
+	}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected paths %v", paths)
+	}
+
+	// invalid names never reach JPush
+	checks := []error{
+		c.TagDelete("a/b", nil),
+		c.TagUpdate("a b", &TagUpdatePayload{Add: []string{"rid"}}),
+		c.AliasUnbind("", []string{"rid"}),
+		c.DeviceSet("rid", NewDeviceSetting().AddTags("ok", "not ok")),
+		c.DeviceSet("rid", NewDeviceSetting().SetAlias("a-b")),
+		c.DeviceSet("", NewDeviceSetting().ClearAlias()),
+	}
+	for i, err := range checks {
+		if _, ok := err.(ValidationError); !ok {
+			t.Errorf("check %d: expected a ValidationError, got %v", i, err)
+		}
+	}
+	if len(paths) != 2 {
+		t.Fatalf("invalid requests were sent: %v", paths[2:])
+	}
+}
@@ -21,8 +21,61 @@ const (
 	PlatformAndroid  Platform = "android"
 	PlatformIOS      Platform = "ios"
 	PlatformWinPhone Platform = "winphone"
+	PlatformQuickApp Platform = "quickapp"
+	PlatformHmos     Platform = "hmos"
 )
 
+// Platforms is the push target set. It marshals to "all" when it contains
+// PlatformAll and to a JSON array otherwise.
+type Platforms []Platform
+
+func NewPlatforms(list ...Platform) Platforms {
+	return Platforms(list)
+}
+
+func (p Platforms) IsAll() bool {
+	for _, v := range p {
+		if v == PlatformAll {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Platforms) Has(platform Platform) bool {
+	for _, v := range p {
+		if v == PlatformAll || v == platform {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Platforms) MarshalJSON() ([]byte, error) {
+	if p.IsAll() {
+		return json.Marshal(PlatformAll)
+	}
+	list := []Platform(p)
+	if list == nil {
+		list = []Platform{}
+	}
+	return json.Marshal(list)
+}
+
+func (p *Platforms) UnmarshalJSON(data []byte) error {
+	var all Platform
+	if err := json.Unmarshal(data, &all); err == nil {
+		*p = Platforms{all}
+		return nil
+	}
+	var list []Platform
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*p = Platforms(list)
+	return nil
+}
+
 type Audience struct {
 	Tag            []string `json:"tag,omitempty"`             // max 20
 	TagAnd         []string `json:"tag_and,omitempty"`         // max 20
@@ -31,12 +84,15 @@ type Audience struct {
 	RegistrationId []string `json:"registration_id,omitempty"` // max 1000
 	Segment        []string `json:"segment,omitempty"`
 	ABTest         []string `json:"abtest,omitempty"`
+	LiveActivityId string   `json:"live_activity_id,omitempty"`
 }
 
 type Notification struct {
-	Alert   string               `json:"alert,omitempty"`
-	Android *NotificationAndroid `json:"android,omitempty"`
-	IOS     *NotificationIOS     `json:"ios,omitempty"`
+	Alert    string                `json:"alert,omitempty"`
+	Android  *NotificationAndroid  `json:"android,omitempty"`
+	IOS      *NotificationIOS      `json:"ios,omitempty"`
+	Hmos     *NotificationHmos     `json:"hmos,omitempty"`
+	QuickApp *NotificationQuickApp `json:"quickapp,omitempty"`
 }
 
 type NotificationAndroid struct {
@@ -66,14 +122,41 @@ type NotificationAndroid struct {
 }
 
 type NotificationIOS struct {
-	Alert            interface{}            `json:"alert"`
-	Sound            string                 `json:"sound,omitempty"`
-	Badge            int                    `json:"badge,int,omitempty"`
-	ContentAvailable bool                   `json:"content-available,omitempty"`
-	MutableContent   bool                   `json:"mutable-content,omitempty"`
-	Category         string                 `json:"category,omitempty"`
-	Extras           map[string]interface{} `json:"extras,omitempty"`
-	ThreadId         string                 `json:"thread-id,omitempty"`
+	Alert             interface{}            `json:"alert"`           // string or *IOSAlert
+	Sound             interface{}            `json:"sound,omitempty"` // string or *IOSSound
+	Badge             int                    `json:"badge,int,omitempty"`
+	ContentAvailable  bool                   `json:"content-available,omitempty"`
+	MutableContent    bool                   `json:"mutable-content,omitempty"`
+	Category          string                 `json:"category,omitempty"`
+	Extras            map[string]interface{} `json:"extras,omitempty"`
+	ThreadId          string                 `json:"thread-id,omitempty"`
+	InterruptionLevel InterruptionLevel      `json:"interruption-level,omitempty"`
+	RelevanceScore    float64                `json:"relevance-score,omitempty"` // 0.0 ~ 1.0
+}
+
+type NotificationHmos struct {
+	Alert       string                 `json:"alert"`
+	Title       string                 `json:"title,omitempty"`
+	Category    string                 `json:"category"` // e.g. IM, ACCOUNT, EXPRESS, MARKETING
+	LargeIcon   string                 `json:"large_icon,omitempty"`
+	Intent      map[string]interface{} `json:"intent,omitempty"`
+	BadgeAddNum int                    `json:"badge_add_num,omitempty"`
+	BadgeSetNum int                    `json:"badge_set_num,omitempty"`
+	TestMessage bool                   `json:"test_message,omitempty"`
+	ReceiptId   string                 `json:"receipt_id,omitempty"`
+	Extras      map[string]interface{} `json:"extras,omitempty"`
+	Style       int                    `json:"style,omitempty"`
+	Inbox       []string               `json:"inbox,omitempty"`
+	PushType    int                    `json:"push_type,omitempty"`
+}
+
+// NotificationQuickApp is the notification of a quick app, which JPush
+// only shows with a title and the page opened on click.
+type NotificationQuickApp struct {
+	Alert  string                 `json:"alert"`
+	Title  string                 `json:"title"`
+	Page   string                 `json:"page"` // e.g. /page1
+	Extras map[string]interface{} `json:"extras,omitempty"`
 }
 
 type Message struct {
@@ -83,32 +166,99 @@ type Message struct {
 	Extras      map[string]interface{} `json:"extras,omitempty"`
 }
 
+// SmsMessage is the SMS supplement sent to the device's bound mobile number
+// when the push is not received within DelayTime seconds.
 type SmsMessage struct {
-	Content   string `json:"content"`
-	DelayTime int    `json:"delay_time,int,omitempty"`
+	Content      string                 `json:"content,omitempty"` // legacy, prefer TempId
+	TempId       int64                  `json:"temp_id,omitempty"`
+	TempPara     map[string]interface{} `json:"temp_para,omitempty"`
+	DelayTime    int                    `json:"delay_time,int,omitempty"` // 0 ~ 86400
+	ActiveFilter *bool                  `json:"active_filter,omitempty"`  // default true: skip users active in the app
 }
 
 type PushOptions struct {
-	SendNo            int                     `json:"sendno,int,omitempty"`
-	TimeToLive        int                     `json:"time_to_live,int,omitempty"`
-	OverrideMsgId     int64                   `json:"override_msg_id,int64,omitempty"`
-	ApnsProduction    bool                    `json:"apns_production"`
-	ApnsCollapseId    string                  `json:"apns_collapse_id,omitempty"`
-	BigPushDuration   int                     `json:"big_push_duration,int,omitempty"`
-	ThirdPartyChannel ThirdPartyChannelOption `json:"third_party_channel,omitempty"`
+	SendNo            int                      `json:"sendno,int,omitempty"`
+	TimeToLive        int                      `json:"time_to_live,int,omitempty"`
+	OverrideMsgId     int64                    `json:"override_msg_id,int64,omitempty"`
+	ApnsProduction    bool                     `json:"apns_production"`
+	ApnsCollapseId    string                   `json:"apns_collapse_id,omitempty"`
+	BigPushDuration   int                      `json:"big_push_duration,int,omitempty"`
+	ThirdPartyChannel *ThirdPartyChannelOption `json:"third_party_channel,omitempty"`
 }
 
+const (
+	DistributionJPush         = "jpush"
+	DistributionOSPush        = "ospush"
+	DistributionSecondaryPush = "secondary_push"
+)
+
+// ThirdPartyChannelOption carries per-vendor settings. Vendors left nil are
+// omitted from the payload, so JPush applies its own defaults to them, and
+// so is an empty Distribution, which keeps the app's configured routing.
 type ThirdPartyChannelOption struct {
-	Xiaomi XiaomiChannel `json:"xiaomi,omitempty"`
-	Huawei HuaweiChannel `json:"huawei,omitempty"`
-	Meizu  MeizuChannel  `json:"meizu,omitempty"`
-	Fcm    FcmChannel    `json:"fcm,omitempty"`
-	Oppo   OppoChannel   `json:"oppo,omitempty"`
-	Vivo   VivoChannel   `json:"vivo,omitempty"`
+	Xiaomi *XiaomiChannel `json:"xiaomi,omitempty"`
+	Huawei *HuaweiChannel `json:"huawei,omitempty"`
+	Meizu  *MeizuChannel  `json:"meizu,omitempty"`
+	Fcm    *FcmChannel    `json:"fcm,omitempty"`
+	Oppo   *OppoChannel   `json:"oppo,omitempty"`
+	Vivo   *VivoChannel   `json:"vivo,omitempty"`
+	Honor  *HonorChannel  `json:"honor,omitempty"`
+	Hmos   *HmosChannel   `json:"hmos,omitempty"`
+}
+
+func NewThirdPartyChannel() *ThirdPartyChannelOption {
+	return &ThirdPartyChannelOption{}
+}
+
+func (o *ThirdPartyChannelOption) WithXiaomi(ch XiaomiChannel) *ThirdPartyChannelOption {
+	o.Xiaomi = &ch
+	return o
+}
+
+func (o *ThirdPartyChannelOption) WithHuawei(ch HuaweiChannel) *ThirdPartyChannelOption {
+	o.Huawei = &ch
+	return o
+}
+
+func (o *ThirdPartyChannelOption) WithMeizu(ch MeizuChannel) *ThirdPartyChannelOption {
+	o.Meizu = &ch
+	return o
+}
+
+func (o *ThirdPartyChannelOption) WithFcm(ch FcmChannel) *ThirdPartyChannelOption {
+	o.Fcm = &ch
+	return o
+}
+
+func (o *ThirdPartyChannelOption) WithOppo(ch OppoChannel) *ThirdPartyChannelOption {
+	o.Oppo = &ch
+	return o
+}
+
+func (o *ThirdPartyChannelOption) WithVivo(ch VivoChannel) *ThirdPartyChannelOption {
+	o.Vivo = &ch
+	return o
+}
+
+func (o *ThirdPartyChannelOption) WithHonor(ch HonorChannel) *ThirdPartyChannelOption {
+	o.Honor = &ch
+	return o
+}
+
+func (o *ThirdPartyChannelOption) WithHmos(ch HmosChannel) *ThirdPartyChannelOption {
+	o.Hmos = &ch
+	return o
+}
+
+// IsEmpty reports whether no vendor is set, in which case the option is
+// left out of the push rather than sent as {}.
+func (o *ThirdPartyChannelOption) IsEmpty() bool {
+	return o == nil || (o.Xiaomi == nil && o.Huawei == nil && o.Meizu == nil && o.Fcm == nil &&
+		o.Oppo == nil && o.Vivo == nil && o.Honor == nil && o.Hmos == nil)
 }
 
 type XiaomiChannel struct {
-	Distribution          string `json:"distribution"` // jpush, ospush, secondary_push
+	Distribution          string `json:"distribution,omitempty"` // jpush, ospush, secondary_push
 	ChannelId             string `json:"channel_id,omitempty"`
 	LargeIcon             string `json:"large_icon,omitempty"`
 	SmallIconUri          string `json:"small_icon_uri,omitempty"`
@@ -120,42 +270,63 @@ type XiaomiChannel struct {
 }
 
 type HuaweiChannel struct {
-	Distribution       string                 `json:"distribution"` // jpush, ospush, secondary_push
+	Distribution       string                 `json:"distribution,omitempty"` // jpush, ospush, secondary_push
 	DistributionFcm    string                 `json:"distribution_fcm,omitempty"`
 	Importance         string                 `json:"importance,omitempty"`
+	Category           string                 `json:"category,omitempty"`
 	LargeIcon          string                 `json:"large_icon,omitempty"`
 	SmallIconUri       string                 `json:"small_icon_uri,omitempty"`
 	SmallIconColor     string                 `json:"small_icon_color,omitempty"`
 	Inbox              map[string]interface{} `json:"inbox,omitempty"`
 	Style              int                    `json:"style,omitempty"`
-	OnlyUseVendorStyle bool                   `json:"only_use_vendor_style"`
+	OnlyUseVendorStyle bool                   `json:"only_use_vendor_style,omitempty"`
 }
 
 type MeizuChannel struct {
-	Distribution    string `json:"distribution"` // jpush, ospush, secondary_push
+	Distribution    string `json:"distribution,omitempty"` // jpush, ospush, secondary_push
 	DistributionFcm string `json:"distribution_fcm,omitempty"`
 }
 
 type FcmChannel struct {
-	Distribution string `json:"distribution"` // jpush, ospush, secondary_push
+	Distribution string `json:"distribution,omitempty"` // jpush, ospush, secondary_push
 }
 
 type OppoChannel struct {
-	Distribution    string `json:"distribution"` // jpush, ospush, secondary_push
+	Distribution    string `json:"distribution,omitempty"` // jpush, ospush, secondary_push
 	ChannelId       string `json:"channel_id,omitempty"`
 	DistributionFcm string `json:"distribution_fcm,omitempty"`
 	LargeIcon       string `json:"large_icon,omitempty"`
 	BigPicPath      string `json:"big_pic_path,omitempty"`
 	Style           int    `json:"style,omitempty"`
+	Category        string `json:"category,omitempty"`
+	NotifyLevel     int    `json:"notify_level,omitempty"`
 }
 
 type VivoChannel struct {
-	Distribution    string `json:"distribution"` // jpush, ospush, secondary_push
-	Classification  string `json:"classification,omitempty"`
+	Distribution    string `json:"distribution,omitempty"`   // jpush, ospush, secondary_push
+	Classification  *int   `json:"classification,omitempty"` // 0 operational, 1 system
+	Category        string `json:"category,omitempty"`
 	DistributionFcm string `json:"distribution_fcm,omitempty"`
 	PushMode        int    `json:"push_mode,omitempty"`
 }
 
+type HonorChannel struct {
+	Distribution    string `json:"distribution,omitempty"` // jpush, ospush, secondary_push
+	DistributionFcm string `json:"distribution_fcm,omitempty"`
+	Importance      string `json:"importance,omitempty"` // LOW, NORMAL
+	LargeIcon       string `json:"large_icon,omitempty"`
+	SmallIconUri    string `json:"small_icon_uri,omitempty"`
+	SmallIconColor  string `json:"small_icon_color,omitempty"`
+	Style           int    `json:"style,omitempty"`
+	BigText         string `json:"big_text,omitempty"`
+}
+
+type HmosChannel struct {
+	Distribution string `json:"distribution,omitempty"` // jpush, ospush, secondary_push
+	Category     string `json:"category,omitempty"`
+	LargeIcon    string `json:"large_icon,omitempty"`
+}
+
 type PushCallback struct {
 	Url    string                 `json:"url,omitempty"`
 	Params map[string]interface{} `json:"params,omitempty"`
@@ -176,7 +347,7 @@ type Notification3rd struct {
 
 type PushPayload struct {
 	Cid             string           `json:"cid,omitempty"`
-	Platform        Platform         `json:"platform"`
+	Platform        Platforms        `json:"platform"`
 	Audience        *Audience        `json:"audience,omitempty"`
 	Notification    *Notification    `json:"notification,omitempty"`
 	Message         *Message         `json:"message,omitempty"`
@@ -184,11 +355,41 @@ type PushPayload struct {
 	Options         *PushOptions     `json:"options,omitempty"`
 	Callback        *PushCallback    `json:"callback,omitempty"`
 	Notification3rd *Notification3rd `json:"notification_3rd,omitempty"`
+	LiveActivity    *LiveActivity    `json:"live_activity,omitempty"`
 }
 
 type PushResult struct {
-	SendNo int    `json:"sendno,int,omitempty"`
-	MsgId  string `json:"msg_id"`
+	SendNo int   `json:"sendno,omitempty"`
+	MsgId  MsgId `json:"msg_id"`
+}
+
+// UnmarshalJSON accepts sendno both as a JSON string, which JPush usually
+// returns, and as a number.
+func (r *PushResult) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		SendNo json.RawMessage `json:"sendno"`
+		MsgId  MsgId           `json:"msg_id"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	r.MsgId = raw.MsgId
+	r.SendNo = 0
+	sendNo := string(raw.SendNo)
+	if len(sendNo) > 0 && sendNo[0] == '"' {
+		if err := json.Unmarshal(raw.SendNo, &sendNo); err != nil {
+			return err
+		}
+	}
+	if sendNo == "" || sendNo == "null" {
+		return nil
+	}
+	n, err := strconv.Atoi(sendNo)
+	if err != nil {
+		return fmt.Errorf("invalid sendno %s", raw.SendNo)
+	}
+	r.SendNo = n
+	return nil
 }
 
 func (c PushClient) Push(payload *PushPayload, validate bool) (*PushResult, error) {
@@ -216,6 +417,45 @@ func (c PushClient) Push(payload *PushPayload, validate bool) (*PushResult, erro
 	return &out, nil
 }
 
+// PushChunked sends payload as several pushes when its alias or
+// registration id audience exceeds the per-push limit. Each push targets
+// one combination of alias and registration id chunks, so the union of all
+// pushes reaches exactly the original audience.
+func (c PushClient) PushChunked(payload *PushPayload) ([]*PushResult, error) {
+	a := payload.Audience
+	if a == nil || (len(a.Alias) <= MaxAudienceAliases && len(a.RegistrationId) <= MaxAudienceRegistrationIds) {
+		res, err := c.Push(payload, false)
+		if err != nil {
+			return nil, err
+		}
+		return []*PushResult{res}, nil
+	}
+
+	aliases := chunkStrings(a.Alias, MaxAudienceAliases)
+	if len(aliases) == 0 {
+		aliases = [][]string{nil}
+	}
+	regIds := chunkStrings(a.RegistrationId, MaxAudienceRegistrationIds)
+	if len(regIds) == 0 {
+		regIds = [][]string{nil}
+	}
+	var list []*PushResult
+	for _, alias := range aliases {
+		for _, regId := range regIds {
+			audience := *a
+			audience.Alias, audience.RegistrationId = alias, regId
+			chunk := *payload
+			chunk.Audience = &audience
+			res, err := c.Push(&chunk, false)
+			if err != nil {
+				return list, err
+			}
+			list = append(list, res)
+		}
+	}
+	return list, nil
+}
+
 type CidPool struct {
 	CidList []string `json:"cidlist"`
 }
@@ -0,0 +1,296 @@
+package jpush
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/sustring/push/common"
+)
+
+func TestPlatformsJSON(t *testing.T) {
+	cases := []struct {
+		platforms Platforms
+		want      string
+	}{
+		{NewPlatforms(PlatformAll), `"all"`},
+		{NewPlatforms(PlatformAndroid), `["android"]`},
+		{NewPlatforms(PlatformAndroid, PlatformIOS, PlatformQuickApp, PlatformHmos), `["android","ios","quickapp","hmos"]`},
+	}
+	for _, c := range cases {
+		buf, err := json.Marshal(c.platforms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != c.want {
+			t.Errorf("marshal %v: got %s, want %s", c.platforms, buf, c.want)
+		}
+		var back Platforms
+		if err := json.Unmarshal(buf, &back); err != nil {
+			t.Fatal(err)
+		}
+		if len(back) != len(c.platforms) {
+			t.Errorf("unmarshal %s: got %v", buf, back)
+		}
+	}
+}
+
+func TestPushOptionsOmitUnsetVendors(t *testing.T) {
+	buf, err := json.Marshal(&PushOptions{ApnsProduction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"apns_production":true}`; string(buf) != want {
+		t.Fatalf("got %s, want %s", buf, want)
+	}
+
+	var none *ThirdPartyChannelOption
+	if !none.IsEmpty() || !NewThirdPartyChannel().IsEmpty() || NewThirdPartyChannel().WithHmos(HmosChannel{}).IsEmpty() {
+		t.Fatal("unexpected IsEmpty")
+	}
+	for _, category := range []common.NotificationCategory{common.CategoryDefault, common.CategorySocial} {
+		payload, _, err := Client{}.buildPushPayload(&common.PushMessageInput{Platform: common.Android, Presentation: true, Alert: "hi", Category: category})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (payload.Options == nil) != (category == common.CategoryDefault) {
+			t.Errorf("category %d: unexpected options %+v", category, payload.Options)
+		}
+	}
+}
+
+func TestThirdPartyChannelJSON(t *testing.T) {
+	operational := 0
+	cases := []struct {
+		option *ThirdPartyChannelOption
+		want   string
+	}{
+		{
+			NewThirdPartyChannel().WithXiaomi(XiaomiChannel{Distribution: DistributionOSPush, ChannelId: "order"}),
+			`{"xiaomi":{"distribution":"ospush","channel_id":"order"}}`,
+		},
+		{
+			NewThirdPartyChannel().WithHuawei(HuaweiChannel{Distribution: DistributionSecondaryPush, Importance: "NORMAL", Category: "IM"}),
+			`{"huawei":{"distribution":"secondary_push","importance":"NORMAL","category":"IM"}}`,
+		},
+		{
+			NewThirdPartyChannel().WithMeizu(MeizuChannel{Distribution: DistributionJPush}),
+			`{"meizu":{"distribution":"jpush"}}`,
+		},
+		{
+			NewThirdPartyChannel().WithFcm(FcmChannel{Distribution: DistributionJPush}),
+			`{"fcm":{"distribution":"jpush"}}`,
+		},
+		{
+			NewThirdPartyChannel().WithOppo(OppoChannel{Distribution: DistributionOSPush, Category: "ORDER", NotifyLevel: 2}),
+			`{"oppo":{"distribution":"ospush","category":"ORDER","notify_level":2}}`,
+		},
+		{
+			NewThirdPartyChannel().WithVivo(VivoChannel{Distribution: DistributionOSPush, Classification: &operational}),
+			`{"vivo":{"distribution":"ospush","classification":0}}`,
+		},
+		{
+			NewThirdPartyChannel().WithHonor(HonorChannel{Distribution: DistributionOSPush, Importance: "LOW"}),
+			`{"honor":{"distribution":"ospush","importance":"LOW"}}`,
+		},
+		{
+			NewThirdPartyChannel().WithHmos(HmosChannel{Distribution: DistributionOSPush, Category: "IM"}),
+			`{"hmos":{"distribution":"ospush","category":"IM"}}`,
+		},
+		{
+			NewThirdPartyChannel().
+				WithXiaomi(XiaomiChannel{Distribution: DistributionJPush}).
+				WithVivo(VivoChannel{Distribution: DistributionJPush, PushMode: 1}),
+			`{"xiaomi":{"distribution":"jpush"},"vivo":{"distribution":"jpush","push_mode":1}}`,
+		},
+	}
+	for _, c := range cases {
+		buf, err := json.Marshal(c.option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != c.want {
+			t.Errorf("got %s, want %s", buf, c.want)
+			continue
+		}
+		var back ThirdPartyChannelOption
+		if err := json.Unmarshal(buf, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&back, c.option) {
+			t.Errorf("round trip of %s: got %+v", buf, back)
+		}
+	}
+}
+
+func TestNotificationIOSJSON(t *testing.T) {
+	n := NewIOSNotification(&IOSAlert{Title: "Order", Body: "Shipped", LocKey: "ORDER_SHIPPED", LocArgs: []string{"42"}}).
+		WithSound(CriticalSound("alarm.caf", 0.8)).
+		WithInterruptionLevel(InterruptionTimeSensitive)
+	buf, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"alert":{"title":"Order","body":"Shipped","loc-key":"ORDER_SHIPPED","loc-args":["42"]},` +
+		`"sound":{"critical":1,"name":"alarm.caf","volume":0.8},"interruption-level":"time-sensitive"}`
+	if string(buf) != want {
+		t.Fatalf("got %s, want %s", buf, want)
+	}
+}
+
+func TestApplyRichContentJSON(t *testing.T) {
+	cases := []struct {
+		rich *common.RichContent
+		want string
+	}{
+		{nil, `{"alert":"hi"}`},
+		{
+			&common.RichContent{Title: "Order", BigText: "Your order has shipped"},
+			`{"alert":"hi","title":"Order","style":1,"big_text":"Your order has shipped"}`,
+		},
+		{
+			&common.RichContent{InboxLines: []string{"one", "two"}, BigText: "ignored"},
+			`{"alert":"hi","style":2,"inbox":{"line01":"one","line02":"two"}}`,
+		},
+		{
+			&common.RichContent{Picture: "https://example.com/a.png", InboxLines: []string{"ignored"}, DeepLink: "myapp://orders/42"},
+			`{"alert":"hi","style":3,"big_pic_path":"https://example.com/a.png","intent":{"url":"myapp://orders/42"}}`,
+		},
+	}
+	for _, c := range cases {
+		n := NewAndroidNotification("hi")
+		applyRichContent(n, c.rich)
+		buf, err := json.Marshal(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != c.want {
+			t.Errorf("rich %+v: got %s, want %s", c.rich, buf, c.want)
+		}
+	}
+}
+
+func TestWithInboxKeepsOrder(t *testing.T) {
+	var lines []string
+	want := `{"alert":"hi","style":2,"inbox":{`
+	for i := 1; i <= 12; i++ {
+		line := fmt.Sprintf("l%d", i)
+		lines = append(lines, line)
+		if i > 1 {
+			want += ","
+		}
+		want += fmt.Sprintf(`"line%02d":"%s"`, i, line)
+	}
+	want += "}}"
+	buf, err := json.Marshal(NewAndroidNotification("hi").WithInbox(lines...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != want {
+		t.Fatalf("got %s, want %s", buf, want)
+	}
+}
+
+func TestActivityIntent(t *testing.T) {
+	for _, c := range []struct{ pkg, activity, want string }{
+		{"com.example", "com.example.OrderActivity", "intent:#Intent;component=com.example/com.example.OrderActivity;end"},
+		{"com.example", ".OrderActivity", "intent:#Intent;component=com.example/com.example.OrderActivity;end"},
+	} {
+		if got := ActivityIntent(c.pkg, c.activity); got != c.want {
+			t.Errorf("ActivityIntent(%q, %q) = %q, want %q", c.pkg, c.activity, got, c.want)
+		}
+	}
+	buf, err := json.Marshal(NewAndroidNotification("hi").WithIntent(ActivityIntent("com.example", ".OrderActivity")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"alert":"hi","intent":{"url":"intent:#Intent;component=com.example/com.example.OrderActivity;end"}}`
+	if string(buf) != want {
+		t.Fatalf("got %s, want %s", buf, want)
+	}
+}
+
+func TestChunkStrings(t *testing.T) {
+	list := []string{"a", "b", "c", "d", "e"}
+	chunks := chunkStrings(list, 2)
+	if !reflect.DeepEqual(chunks, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}) {
+		t.Fatalf("got %v", chunks)
+	}
+	chunks[0] = append(chunks[0], "x")
+	if list[2] != "c" {
+		t.Fatal("appending to a chunk overwrote the next one")
+	}
+	if chunkStrings(nil, 2) != nil {
+		t.Fatal("expected no chunks for an empty list")
+	}
+}
+
+func TestMsgIdJSON(t *testing.T) {
+	var r ReceivedDetailResult
+	if err := json.Unmarshal([]byte(`{"msg_id":67554217262909280,"jpush_received":1}`), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.MsgId != "67554217262909280" {
+		t.Fatalf("got %q", r.MsgId)
+	}
+	var p PushResult
+	if err := json.Unmarshal([]byte(`{"sendno":"0","msg_id":"67554217262909280"}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	for body, want := range map[string]int{
+		`{"sendno":"42","msg_id":1}`: 42,
+		`{"sendno":42,"msg_id":1}`:   42,
+		`{"sendno":"","msg_id":1}`:   0,
+		`{"msg_id":1}`:               0,
+	} {
+		var r PushResult
+		if err := json.Unmarshal([]byte(body), &r); err != nil || r.SendNo != want || r.MsgId != "1" {
+			t.Errorf("%s: got %+v, %v", body, r, err)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"sendno":"x","msg_id":1}`), new(PushResult)); err == nil {
+		t.Error("expected an error for a non-numeric sendno")
+	}
+	buf, err := json.Marshal(&MessageStatusPayload{MsgId: p.MsgId, RegistrationIds: []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"msg_id":67554217262909280,"registration_ids":["a"]}`; string(buf) != want {
+		t.Fatalf("got %s, want %s", buf, want)
+	}
+}
+
+func TestSmsMessageJSON(t *testing.T) {
+	active := false
+	payload, _, err := Client{}.buildPushPayload(&common.PushMessageInput{
+		Platform:     common.Android,
+		Presentation: true,
+		Alert:        "hello",
+		Sms: &common.SmsFallback{
+			TemplateId:     1001,
+			TemplateParams: map[string]interface{}{"code": "8421"},
+			DelaySeconds:   300,
+			ActiveFilter:   &active,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := json.Marshal(payload.SmsMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"temp_id":1001,"temp_para":{"code":"8421"},"delay_time":300,"active_filter":false}`; string(buf) != want {
+		t.Fatalf("got %s, want %s", buf, want)
+	}
+
+	// unset fields keep JPush's defaults
+	buf, err = json.Marshal(&SmsMessage{TempId: 1001})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"temp_id":1001}`; string(buf) != want {
+		t.Fatalf("got %s, want %s", buf, want)
+	}
+}
@@ -0,0 +1,329 @@
+package jpush
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrDeviceNotFound = errors.New("device not found in registry")
+
+// RegistryEntry is the local view of one device.
+type RegistryEntry struct {
+	RegistrationId string
+	Alias          string
+	Tags           []string
+	Mobile         string
+	Platform       Platform // empty until reported through Seen
+	LastSeen       time.Time
+	Eligible       bool // false once JPush reported the id as invalid
+}
+
+// RegistryStore persists registry entries. Get returns ErrDeviceNotFound
+// for unknown ids, and implementations return copies.
+type RegistryStore interface {
+	Get(registrationId string) (*RegistryEntry, error)
+	Put(e *RegistryEntry) error
+	List() ([]*RegistryEntry, error)
+}
+
+// Registry mirrors what has been set on devices through DeviceClient, so
+// callers can know a device's alias and tags without asking JPush. Set it on
+// DeviceClient.Registry to record every successful update.
+type Registry struct {
+	store RegistryStore
+	mu    sync.Mutex
+	now   func() time.Time
+}
+
+func NewRegistry(store RegistryStore) *Registry {
+	return &Registry{store: store, now: time.Now}
+}
+
+func (r *Registry) Get(registrationId string) (*RegistryEntry, error) {
+	return r.store.Get(registrationId)
+}
+
+func (r *Registry) List() ([]*RegistryEntry, error) {
+	return r.store.List()
+}
+
+// update applies fn to the entry of registrationId, creating it if needed.
+func (r *Registry) update(registrationId string, fn func(e *RegistryEntry)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, err := r.store.Get(registrationId)
+	if err == ErrDeviceNotFound {
+		e = &RegistryEntry{RegistrationId: registrationId, Eligible: true}
+	} else if err != nil {
+		return err
+	}
+	fn(e)
+	return r.store.Put(e)
+}
+
+// TagMembers lists the registration ids carrying tag, sorted.
+func (r *Registry) TagMembers(tag string) ([]string, error) {
+	list, err := r.store.List()
+	if err != nil {
+		return nil, err
+	}
+	var members []string
+	for _, e := range list {
+		if containsString(e.Tags, tag) {
+			members = append(members, e.RegistrationId)
+		}
+	}
+	return members, nil
+}
+
+// Seen records that the app reported the device, e.g. on login.
+func (r *Registry) Seen(registrationId string, platform Platform) error {
+	return r.update(registrationId, func(e *RegistryEntry) {
+		if platform != "" {
+			e.Platform = platform
+		}
+		e.LastSeen = r.now()
+		e.Eligible = true
+	})
+}
+
+// MarkIneligible records that pushes to the device cannot be delivered.
+// A tracker given the registry calls it when MessageStatus reports the id
+// as invalid.
+func (r *Registry) MarkIneligible(registrationId string) error {
+	return r.update(registrationId, func(e *RegistryEntry) {
+		e.Eligible = false
+	})
+}
+
+func (r *Registry) recordDeviceSet(registrationId string, payload *DeviceSettingPayload) error {
+	return r.update(registrationId, func(e *RegistryEntry) {
+		if payload.Alias != nil {
+			e.Alias = *payload.Alias
+		}
+		if payload.Mobile != nil {
+			e.Mobile = *payload.Mobile
+		}
+		switch tags := payload.Tags.(type) {
+		case string:
+			if tags == "" {
+				e.Tags = nil
+			}
+		case *DeviceSettingRequestTags:
+			if tags != nil {
+				e.Tags = applyTagChanges(e.Tags, tags.Add, tags.Remove)
+			}
+		}
+	})
+}
+
+func (r *Registry) recordTagUpdate(tag string, payload *TagUpdatePayload) error {
+	for _, regId := range payload.Add {
+		err := r.update(regId, func(e *RegistryEntry) {
+			e.Tags = applyTagChanges(e.Tags, []string{tag}, nil)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, regId := range payload.Remove {
+		err := r.update(regId, func(e *RegistryEntry) {
+			e.Tags = applyTagChanges(e.Tags, nil, []string{tag})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordAll applies fn to every known device matching match.
+func (r *Registry) recordAll(match func(e *RegistryEntry) bool, fn func(e *RegistryEntry)) error {
+	list, err := r.store.List()
+	if err != nil {
+		return err
+	}
+	for _, e := range list {
+		if !match(e) {
+			continue
+		}
+		if err := r.update(e.RegistrationId, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) recordTagDelete(tag string) error {
+	return r.recordAll(func(e *RegistryEntry) bool { return containsString(e.Tags, tag) }, func(e *RegistryEntry) {
+		e.Tags = applyTagChanges(e.Tags, nil, []string{tag})
+	})
+}
+
+func (r *Registry) recordAliasDelete(alias string, registrationIds []string) error {
+	return r.recordAll(func(e *RegistryEntry) bool {
+		return e.Alias == alias && (registrationIds == nil || containsString(registrationIds, e.RegistrationId))
+	}, func(e *RegistryEntry) {
+		e.Alias = ""
+	})
+}
+
+func applyTagChanges(tags, add, remove []string) []string {
+	set := make(map[string]bool, len(tags)+len(add))
+	for _, t := range tags {
+		set[t] = true
+	}
+	for _, t := range add {
+		set[t] = true
+	}
+	for _, t := range remove {
+		delete(set, t)
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(set))
+	for t := range set {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type DriftKind int
+
+const (
+	DriftAlias DriftKind = iota + 1
+	DriftTags
+	DriftMobile
+	DriftUnavailable // DeviceView failed, see Err
+)
+
+// Drift is one difference between the registry and JPush.
+type Drift struct {
+	RegistrationId string
+	Kind           DriftKind
+	Local          []string
+	Remote         []string
+	Err            error
+}
+
+// DeviceViewer is implemented by DeviceClient.
+type DeviceViewer interface {
+	DeviceView(registrationId string) (*Device, error)
+}
+
+// Reconcile compares every eligible device with DeviceView and reports the
+// differences. The registry is left untouched; pass fix to overwrite local
+// entries with JPush's view.
+func (r *Registry) Reconcile(c DeviceViewer, fix bool) ([]*Drift, error) {
+	list, err := r.store.List()
+	if err != nil {
+		return nil, err
+	}
+	var drifts []*Drift
+	for _, e := range list {
+		if !e.Eligible {
+			continue
+		}
+		remote, err := c.DeviceView(e.RegistrationId)
+		if err != nil {
+			drifts = append(drifts, &Drift{RegistrationId: e.RegistrationId, Kind: DriftUnavailable, Err: err})
+			continue
+		}
+		found := compareDevice(e, remote)
+		drifts = append(drifts, found...)
+		if fix && len(found) > 0 {
+			err := r.update(e.RegistrationId, func(e *RegistryEntry) {
+				e.Alias = remote.Alias
+				e.Mobile = remote.Mobile
+				e.Tags = applyTagChanges(nil, remote.Tags, nil)
+			})
+			if err != nil {
+				return drifts, err
+			}
+		}
+	}
+	return drifts, nil
+}
+
+func compareDevice(e *RegistryEntry, remote *Device) []*Drift {
+	var drifts []*Drift
+	if e.Alias != remote.Alias {
+		drifts = append(drifts, &Drift{RegistrationId: e.RegistrationId, Kind: DriftAlias, Local: []string{e.Alias}, Remote: []string{remote.Alias}})
+	}
+	if e.Mobile != remote.Mobile {
+		drifts = append(drifts, &Drift{RegistrationId: e.RegistrationId, Kind: DriftMobile, Local: []string{e.Mobile}, Remote: []string{remote.Mobile}})
+	}
+	local, other := applyTagChanges(nil, e.Tags, nil), applyTagChanges(nil, remote.Tags, nil)
+	if !equalStrings(local, other) {
+		drifts = append(drifts, &Drift{RegistrationId: e.RegistrationId, Kind: DriftTags, Local: local, Remote: other})
+	}
+	return drifts
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// MemoryRegistryStore keeps entries in memory only.
+type MemoryRegistryStore struct {
+	mu      sync.RWMutex
+	entries map[string]RegistryEntry
+}
+
+func NewMemoryRegistryStore() *MemoryRegistryStore {
+	return &MemoryRegistryStore{entries: make(map[string]RegistryEntry)}
+}
+
+func (m *MemoryRegistryStore) Get(registrationId string) (*RegistryEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.entries[registrationId]
+	if !ok {
+		return nil, ErrDeviceNotFound
+	}
+	e.Tags = append([]string(nil), e.Tags...)
+	return &e, nil
+}
+
+func (m *MemoryRegistryStore) Put(e *RegistryEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *e
+	copied.Tags = append([]string(nil), e.Tags...)
+	m.entries[e.RegistrationId] = copied
+	return nil
+}
+
+func (m *MemoryRegistryStore) List() ([]*RegistryEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]*RegistryEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		e.Tags = append([]string(nil), e.Tags...)
+		copied := e
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].RegistrationId < list[j].RegistrationId })
+	return list, nil
+}
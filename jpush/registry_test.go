@@ -0,0 +1,99 @@
+package jpush
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryRecordsDeviceUpdates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	registry := NewRegistry(NewMemoryRegistryStore())
+	c := DeviceClient{BaseClient: &BaseClient{}, url: server.URL, Registry: registry}
+
+	if err := registry.Seen("rid1", PlatformAndroid); err != nil {
+		t.Fatal(err)
+	}
+	err := c.DeviceSet("rid1", NewDeviceSetting().SetAlias("u1").AddTags("vip", "beta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.TagUpdate("beta", &TagUpdatePayload{Add: []string{"rid2"}, Remove: []string{"rid1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := registry.Get("rid1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Alias != "u1" || strings.Join(e.Tags, ",") != "vip" || e.Platform != PlatformAndroid || e.LastSeen.IsZero() || !e.Eligible {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	e, err = registry.Get("rid2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(e.Tags, ",") != "beta" {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+
+	if err := c.AliasUnbind("u1", []string{"rid1"}); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := registry.Get("rid1"); e.Alias != "" {
+		t.Fatalf("alias not cleared: %+v", e)
+	}
+	if _, err := registry.Get("rid3"); err != ErrDeviceNotFound {
+		t.Fatalf("expected ErrDeviceNotFound, got %v", err)
+	}
+}
+
+type fakeViewer map[string]*Device
+
+func (f fakeViewer) DeviceView(registrationId string) (*Device, error) {
+	d, ok := f[registrationId]
+	if !ok {
+		return nil, ErrDeviceNotFound
+	}
+	return d, nil
+}
+
+func TestRegistryReconcile(t *testing.T) {
+	registry := NewRegistry(NewMemoryRegistryStore())
+	registry.recordDeviceSet("rid1", NewDeviceSetting().SetAlias("u1").AddTags("a", "b"))
+	registry.recordDeviceSet("rid2", NewDeviceSetting().SetAlias("u2"))
+	registry.recordDeviceSet("rid3", NewDeviceSetting().ClearAlias())
+	registry.MarkIneligible("rid4")
+
+	viewer := fakeViewer{
+		"rid1": {Alias: "u1", Tags: []string{"b", "a"}},
+		"rid2": {Alias: "other", Tags: []string{"c"}},
+	}
+	drifts, err := registry.Reconcile(viewer, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []DriftKind
+	for _, d := range drifts {
+		kinds = append(kinds, d.Kind)
+		if d.RegistrationId == "rid1" || d.RegistrationId == "rid4" {
+			t.Fatalf("unexpected drift %+v", d)
+		}
+	}
+	if len(kinds) != 3 || kinds[0] != DriftAlias || kinds[1] != DriftTags || kinds[2] != DriftUnavailable {
+		t.Fatalf("unexpected drifts %v", kinds)
+	}
+
+	e, _ := registry.Get("rid2")
+	if e.Alias != "other" || strings.Join(e.Tags, ",") != "c" {
+		t.Fatalf("entry not fixed: %+v", e)
+	}
+	if drifts, _ := registry.Reconcile(viewer, false); len(drifts) != 1 {
+		t.Fatalf("expected only rid3 to drift after fix, got %d", len(drifts))
+	}
+}
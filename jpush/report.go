@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -13,16 +15,47 @@ type ReportClient struct {
 	url string
 }
 
+// MsgId is a JPush message id. JPush sends ids as JSON numbers beyond 32
+// bits in some responses and as strings in others; MsgId accepts both and
+// marshals numeric ids back as numbers.
+type MsgId string
+
+func (id MsgId) MarshalJSON() ([]byte, error) {
+	if _, err := strconv.ParseUint(string(id), 10, 64); err == nil {
+		return []byte(id), nil
+	}
+	return json.Marshal(string(id))
+}
+
+func (id *MsgId) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var v string
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*id = MsgId(v)
+		return nil
+	}
+	if string(data) == "null" {
+		return nil
+	}
+	if _, err := strconv.ParseUint(string(data), 10, 64); err != nil {
+		return fmt.Errorf("invalid msg id %s", data)
+	}
+	*id = MsgId(data)
+	return nil
+}
+
 type ReceivedDetailResult struct {
-	MsgId                 string `json:"msg_id"`
-	JPushReceived         int    `json:"jpush_received"`
-	AndroidPNSSent        int    `json:"android_pns_sent"`
-	IOSAPNSSent           int    `json:"ios_apns_sent"`
-	IOSAPNSReceived       int    `json:"ios_apns_received"`
-	IOSMsgReceived        int    `json:"ios_msg_received"`
-	WPMPNSSent            int    `json:"wp_mpns_sent"`
-	QuickappJpushReceived int    `json:"quickapp_jpush_received"`
-	QuickappPNSSent       int    `json:"quickapp_pns_sent"`
+	MsgId                 MsgId `json:"msg_id"`
+	JPushReceived         int   `json:"jpush_received"`
+	AndroidPNSSent        int   `json:"android_pns_sent"`
+	IOSAPNSSent           int   `json:"ios_apns_sent"`
+	IOSAPNSReceived       int   `json:"ios_apns_received"`
+	IOSMsgReceived        int   `json:"ios_msg_received"`
+	WPMPNSSent            int   `json:"wp_mpns_sent"`
+	QuickappJpushReceived int   `json:"quickapp_jpush_received"`
+	QuickappPNSSent       int   `json:"quickapp_pns_sent"`
 }
 
 func (c ReportClient) ReceivedDetail(msgIds []string) ([]*ReceivedDetailResult, error) {
@@ -46,17 +79,69 @@ func (c ReportClient) ReceivedDetail(msgIds []string) ([]*ReceivedDetailResult,
 	return list, nil
 }
 
+// MaxMessageStatusRegistrationIds is how many registration ids
+// MessageStatus accepts per call.
+const MaxMessageStatusRegistrationIds = 1000
+
 type MessageStatusPayload struct {
-	MsgId           int      `json:"msg_id,int"`
+	MsgId           MsgId    `json:"msg_id"`
 	RegistrationIds []string `json:"registration_ids"`
 	Date            string   `json:"date,omitempty"` //  format:yyyy-mm-dd
 }
 
+type DeliveryStatus int
+
+const (
+	MessageStatusUnknown         DeliveryStatus = -1 // not reported yet
+	MessageDelivered             DeliveryStatus = 0
+	MessageNotDelivered          DeliveryStatus = 1
+	MessageInvalidRegistrationId DeliveryStatus = 2 // not a device of this app
+	MessageNotTargeted           DeliveryStatus = 3 // device of this app, but not in the audience
+	MessageSystemError           DeliveryStatus = 4
+)
+
+var deliveryStatusNames = map[DeliveryStatus]string{
+	MessageStatusUnknown:         "unknown",
+	MessageDelivered:             "delivered",
+	MessageNotDelivered:          "not delivered",
+	MessageInvalidRegistrationId: "invalid registration id",
+	MessageNotTargeted:           "not targeted",
+	MessageSystemError:           "system error",
+}
+
+func (s DeliveryStatus) String() string {
+	if name, ok := deliveryStatusNames[s]; ok {
+		return name
+	}
+	return "status " + strconv.Itoa(int(s))
+}
+
 type MessageStatusResult struct {
-	Status int `json:"status"`
+	Status DeliveryStatus `json:"status"`
 }
 
+// MessageStatus reports the delivery status of a message per registration
+// id, splitting the ids into calls of MaxMessageStatusRegistrationIds.
 func (c ReportClient) MessageStatus(payload *MessageStatusPayload) (map[string]*MessageStatusResult, error) {
+	if payload.MsgId == "" {
+		return nil, errors.New("invalid msg id")
+	}
+	out := make(map[string]*MessageStatusResult, len(payload.RegistrationIds))
+	for _, regIds := range chunkStrings(payload.RegistrationIds, MaxMessageStatusRegistrationIds) {
+		chunk := *payload
+		chunk.RegistrationIds = regIds
+		params, err := c.messageStatus(&chunk)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range params {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+func (c ReportClient) messageStatus(payload *MessageStatusPayload) (map[string]*MessageStatusResult, error) {
 	link := c.url + "/v3/status/message"
 	buf, err := json.Marshal(payload)
 	if err != nil {
@@ -66,6 +151,9 @@ func (c ReportClient) MessageStatus(payload *MessageStatusPayload) (map[string]*
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
 	params := make(map[string]*MessageStatusResult)
 	err = json.Unmarshal(resp.Bytes(), &params)
 	if err != nil {
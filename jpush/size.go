@@ -0,0 +1,198 @@
+package jpush
+
+import (
+	"errors"
+	"unicode"
+)
+
+const alertEllipsis = "…"
+
+// ErrNotificationTooLarge is returned by TruncateAlert when a notification
+// is over its size limit even with the alert cut to one character, e.g.
+// because of its extras.
+var ErrNotificationTooLarge = errors.New("notification exceeds the size limit even with the alert cut to one character")
+
+// PayloadSize returns the size in bytes of the notification JPush builds
+// for platform: the "android" object for Android, and the APNs payload
+// (aps dictionary plus top-level extras) for iOS. It returns 0 when the
+// payload carries nothing for that platform.
+func (p *PushPayload) PayloadSize(platform Platform) int {
+	n := p.Notification
+	if n == nil || !p.targets(platform) {
+		return 0
+	}
+	switch platform {
+	case PlatformAndroid:
+		if n.Android == nil && n.Alert == "" {
+			return 0
+		}
+		android := n.Android
+		if android == nil {
+			android = &NotificationAndroid{}
+		}
+		return jsonSize(android.withAlert(n.Alert))
+	case PlatformIOS:
+		if n.IOS == nil && n.Alert == "" {
+			return 0
+		}
+		ios := n.IOS
+		if ios == nil {
+			ios = &NotificationIOS{}
+		}
+		return jsonSize(ios.withAlert(n.Alert).apns())
+	}
+	return 0
+}
+
+// apns mirrors the payload JPush forwards to APNs, where extras are merged
+// into the top level next to the aps dictionary.
+func (n *NotificationIOS) apns() map[string]interface{} {
+	aps := map[string]interface{}{"alert": n.Alert}
+	if n.Sound != nil && n.Sound != "" {
+		aps["sound"] = n.Sound
+	}
+	if n.Badge != 0 {
+		aps["badge"] = n.Badge
+	}
+	if n.ContentAvailable {
+		aps["content-available"] = 1
+	}
+	if n.MutableContent {
+		aps["mutable-content"] = 1
+	}
+	if n.Category != "" {
+		aps["category"] = n.Category
+	}
+	if n.InterruptionLevel != "" {
+		aps["interruption-level"] = n.InterruptionLevel
+	}
+	if n.RelevanceScore != 0 {
+		aps["relevance-score"] = n.RelevanceScore
+	}
+	if n.ThreadId != "" {
+		aps["thread-id"] = n.ThreadId
+	}
+	out := make(map[string]interface{}, len(n.Extras)+1)
+	for k, v := range n.Extras {
+		out[k] = v
+	}
+	out["aps"] = aps
+	return out
+}
+
+func (p *PushPayload) fits() bool {
+	return p.PayloadSize(PlatformAndroid) <= MaxAndroidNotificationBytes &&
+		p.PayloadSize(PlatformIOS) <= MaxIOSNotificationBytes
+}
+
+// TruncateAlert shortens the notification alerts so every targeted platform
+// fits its size limit, cutting on a character boundary and appending an
+// ellipsis. It reports whether any alert was changed, and returns
+// ErrNotificationTooLarge, leaving every alert as it was, when no cut makes
+// the notification fit.
+func (p *PushPayload) TruncateAlert() (bool, error) {
+	n := p.Notification
+	if n == nil || p.fits() {
+		return false, nil
+	}
+	// cuts are measured in place, each on top of the previous ones, and all
+	// put back when a later one fails
+	var restore []func()
+	fail := func(err error) (bool, error) {
+		for i := len(restore) - 1; i >= 0; i-- {
+			restore[i]()
+		}
+		return false, err
+	}
+	truncated := false
+	if n.Android != nil && n.Android.Alert != "" {
+		orig := n.Android.Alert
+		restore = append(restore, func() { n.Android.Alert = orig })
+		alert, ok, err := fitAlert(orig, func(s string) bool {
+			n.Android.Alert = s
+			return p.PayloadSize(PlatformAndroid) <= MaxAndroidNotificationBytes
+		})
+		if err != nil {
+			return fail(err)
+		}
+		n.Android.Alert = alert
+		truncated = truncated || ok
+	}
+	if n.IOS != nil {
+		if body, set := n.IOS.alertBody(); body != "" {
+			restore = append(restore, func() { set(body) })
+			alert, ok, err := fitAlert(body, func(s string) bool {
+				set(s)
+				return p.PayloadSize(PlatformIOS) <= MaxIOSNotificationBytes
+			})
+			if err != nil {
+				return fail(err)
+			}
+			set(alert)
+			truncated = truncated || ok
+		}
+	}
+	if n.Alert != "" {
+		orig := n.Alert
+		restore = append(restore, func() { n.Alert = orig })
+		alert, ok, err := fitAlert(orig, func(s string) bool {
+			n.Alert = s
+			return p.fits()
+		})
+		if err != nil {
+			return fail(err)
+		}
+		n.Alert = alert
+		truncated = truncated || ok
+	}
+	if !p.fits() {
+		return fail(ErrNotificationTooLarge)
+	}
+	return truncated, nil
+}
+
+// fitAlert returns the longest prefix of alert, followed by an ellipsis,
+// for which fits reports true. The cut never splits a grapheme cluster made
+// of a base character and its combining marks or joiners. It returns
+// ErrNotificationTooLarge when not even the first cluster fits.
+func fitAlert(alert string, fits func(string) bool) (string, bool, error) {
+	if fits(alert) {
+		return alert, false, nil
+	}
+	runes := []rune(alert)
+	if len(runes) < 2 || !fits(cutAlert(runes, 1)) {
+		return "", false, ErrNotificationTooLarge
+	}
+	lo, hi := 1, len(runes)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if fits(cutAlert(runes, mid)) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	k := lo
+	for k > 0 && (isGraphemeExtend(runes[k]) || runes[k-1] == zeroWidthJoiner) {
+		k--
+	}
+	// the only cuts that fit split the first grapheme cluster
+	if k == 0 {
+		return "", false, ErrNotificationTooLarge
+	}
+	return cutAlert(runes, k), true, nil
+}
+
+func cutAlert(runes []rune, n int) string {
+	return string(runes[:n]) + alertEllipsis
+}
+
+const zeroWidthJoiner = '\u200d'
+
+func isGraphemeExtend(r rune) bool {
+	return r == zeroWidthJoiner ||
+		unicode.Is(unicode.Mn, r) ||
+		unicode.Is(unicode.Me, r) ||
+		unicode.Is(unicode.Variation_Selector, r) ||
+		(r >= 0x1f3fb && r <= 0x1f3ff) // emoji skin tone modifiers
+}
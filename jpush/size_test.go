@@ -0,0 +1,96 @@
+package jpush
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPushPayloadTruncateAlert(t *testing.T) {
+	payload := &PushPayload{
+		Platform: NewPlatforms(PlatformAll),
+		Audience: &Audience{Alias: []string{"qiuqiankun"}},
+		Notification: &Notification{
+			Alert: strings.Repeat("推送", 1000),
+			IOS: &NotificationIOS{
+				ThreadId: "order",
+				Extras:   map[string]interface{}{"msg_id": 123},
+			},
+		},
+	}
+	if payload.Validate() == nil {
+		t.Fatal("expected oversized payload to fail validation")
+	}
+	if ok, err := payload.TruncateAlert(); !ok || err != nil {
+		t.Fatalf("expected alert to be truncated, got %v", err)
+	}
+	if err := payload.Validate(); err != nil {
+		t.Fatalf("truncated payload: %v", err)
+	}
+	alert := payload.Notification.Alert
+	if !utf8.ValidString(alert) || !strings.HasSuffix(alert, alertEllipsis) {
+		t.Fatalf("bad truncated alert %q", alert)
+	}
+	if size := payload.PayloadSize(PlatformIOS); size > MaxIOSNotificationBytes || size < MaxIOSNotificationBytes-8 {
+		t.Fatalf("ios size %d not close to limit", size)
+	}
+	if ok, err := payload.TruncateAlert(); ok || err != nil {
+		t.Fatalf("second truncation should be a no-op, got %v", err)
+	}
+}
+
+func TestFitAlertKeepsGraphemes(t *testing.T) {
+	alert := "e\u0301e\u0301e\u0301"
+	got, ok, err := fitAlert(alert, func(s string) bool { return utf8.RuneCountInString(s) <= 3 })
+	if !ok || err != nil || got != "e\u0301"+alertEllipsis {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestTruncateAlertOversizedExtras(t *testing.T) {
+	payload := &PushPayload{
+		Platform: NewPlatforms(PlatformAndroid),
+		Audience: &Audience{Alias: []string{"qiuqiankun"}},
+		Notification: &Notification{
+			Android: &NotificationAndroid{
+				Alert:  "hello",
+				Extras: map[string]interface{}{"blob": strings.Repeat("x", MaxAndroidNotificationBytes)},
+			},
+		},
+	}
+	if ok, err := payload.TruncateAlert(); ok || err != ErrNotificationTooLarge {
+		t.Fatalf("expected ErrNotificationTooLarge, got %v, %v", ok, err)
+	}
+	if _, _, err := fitAlert("hello", func(s string) bool { return false }); err != ErrNotificationTooLarge {
+		t.Fatalf("expected ErrNotificationTooLarge, got %v", err)
+	}
+}
+
+func TestFitAlertFirstClusterTooLong(t *testing.T) {
+	// a base character with three combining marks, where only two runes fit
+	alert := "e\u0301\u0302\u0303x"
+	if got, _, err := fitAlert(alert, func(s string) bool { return utf8.RuneCountInString(s) <= 3 }); err != ErrNotificationTooLarge {
+		t.Fatalf("expected ErrNotificationTooLarge, got %q, %v", got, err)
+	}
+}
+
+func TestTruncateAlertLeavesPayloadOnError(t *testing.T) {
+	android := strings.Repeat("a", MaxAndroidNotificationBytes)
+	payload := &PushPayload{
+		Platform: NewPlatforms(PlatformAndroid, PlatformIOS),
+		Audience: &Audience{Alias: []string{"qiuqiankun"}},
+		Notification: &Notification{
+			Android: &NotificationAndroid{Alert: android},
+			IOS: &NotificationIOS{
+				Alert:  "hello",
+				Extras: map[string]interface{}{"blob": strings.Repeat("x", MaxIOSNotificationBytes)},
+			},
+		},
+	}
+	if _, err := payload.TruncateAlert(); err != ErrNotificationTooLarge {
+		t.Fatalf("expected ErrNotificationTooLarge, got %v", err)
+	}
+	if payload.Notification.Android.Alert != android || payload.Notification.IOS.Alert != "hello" {
+		t.Fatal("alerts were changed by a failed truncation")
+	}
+}
@@ -0,0 +1,217 @@
+package jpush
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxReportMsgIds is how many msg ids the report endpoints accept per call.
+const MaxReportMsgIds = 100
+
+// MessageStats are the funnel counters of one platform or vendor channel.
+type MessageStats struct {
+	Target     int `json:"target"`
+	OnlinePush int `json:"online_push"`
+	Received   int `json:"received"`
+	Display    int `json:"display"`
+	Click      int `json:"click"`
+	MsgClick   int `json:"msg_click"`
+}
+
+type AndroidMessageStats struct {
+	MessageStats
+	// Vendors breaks the counters down by delivery channel, keyed by the
+	// names JPush reports, e.g. jpush, xiaomi, huawei, meizu, oppo, vivo, fcm.
+	Vendors map[string]*MessageStats `json:"sub_android,omitempty"`
+}
+
+type IOSMessageStats struct {
+	ApnsTarget   int `json:"apns_target"`
+	ApnsSent     int `json:"apns_sent"`
+	ApnsReceived int `json:"apns_received"`
+	ApnsDisplay  int `json:"apns_display"`
+	ApnsClick    int `json:"apns_click"`
+	MsgTarget    int `json:"msg_target"`
+	MsgReceived  int `json:"msg_received"`
+	MsgClick     int `json:"msg_click"`
+}
+
+type PlatformMessageStats struct {
+	Android  *AndroidMessageStats `json:"android,omitempty"`
+	IOS      *IOSMessageStats     `json:"ios,omitempty"`
+	QuickApp *MessageStats        `json:"quickapp,omitempty"`
+	Hmos     *MessageStats        `json:"hmos,omitempty"`
+}
+
+type MessageDetail struct {
+	Notification *PlatformMessageStats `json:"notification,omitempty"`
+	Message      *PlatformMessageStats `json:"message,omitempty"`
+}
+
+type MessagesDetailResult struct {
+	MsgId   MsgId          `json:"msg_id"`
+	Details *MessageDetail `json:"details"`
+}
+
+// MessagesDetail reports target, sent, received, displayed and clicked
+// counts per platform and vendor channel for up to MaxReportMsgIds messages.
+func (c ReportClient) MessagesDetail(msgIds []string) ([]*MessagesDetailResult, error) {
+	var list []*MessagesDetailResult
+	err := c.getMsgIds("/v3/messages/detail", msgIds, &list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ReceivedResult is the legacy received report.
+type ReceivedResult struct {
+	MsgId           MsgId `json:"msg_id"`
+	AndroidReceived int   `json:"android_received"`
+	IOSAPNSSent     int   `json:"ios_apns_sent"`
+	IOSAPNSReceived int   `json:"ios_apns_received"`
+	IOSMsgReceived  int   `json:"ios_msg_received"`
+	WPMPNSSent      int   `json:"wp_mpns_sent"`
+}
+
+// Received is the legacy /v3/received report; prefer ReceivedDetail.
+func (c ReportClient) Received(msgIds []string) ([]*ReceivedResult, error) {
+	var list []*ReceivedResult
+	err := c.getMsgIds("/v3/received", msgIds, &list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c ReportClient) getMsgIds(path string, msgIds []string, out interface{}) error {
+	if len(msgIds) == 0 || len(msgIds) > MaxReportMsgIds {
+		return errors.New("invalid msg id")
+	}
+	link := c.url + path + "?msg_ids=" + strings.Join(msgIds, ",")
+	resp, err := c.Request("GET", link, nil, false)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return newAPIError(resp)
+	}
+	return json.Unmarshal(resp.Bytes(), out)
+}
+
+type TimeUnit string
+
+const (
+	TimeUnitHour  TimeUnit = "HOUR"
+	TimeUnitDay   TimeUnit = "DAY"
+	TimeUnitMonth TimeUnit = "MONTH"
+)
+
+var timeUnitLayouts = map[TimeUnit]string{
+	TimeUnitHour:  "2006-01-02 15",
+	TimeUnitDay:   "2006-01-02",
+	TimeUnitMonth: "2006-01",
+}
+
+// maxTimeUnitDuration is the longest range JPush serves per unit.
+var maxTimeUnitDuration = map[TimeUnit]int{
+	TimeUnitHour:  24,
+	TimeUnitDay:   60,
+	TimeUnitMonth: 2,
+}
+
+// TimeRange selects Duration consecutive units starting at Start, in the
+// app's local time as configured in JPush.
+type TimeRange struct {
+	Unit     TimeUnit
+	Start    time.Time
+	Duration int
+}
+
+func Hours(start time.Time, n int) TimeRange {
+	return TimeRange{Unit: TimeUnitHour, Start: start, Duration: n}
+}
+
+func Days(start time.Time, n int) TimeRange {
+	return TimeRange{Unit: TimeUnitDay, Start: start, Duration: n}
+}
+
+func Months(start time.Time, n int) TimeRange {
+	return TimeRange{Unit: TimeUnitMonth, Start: start, Duration: n}
+}
+
+// LastDays is the n whole days before the day of now.
+func LastDays(now time.Time, n int) TimeRange {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return Days(day.AddDate(0, 0, -n), n)
+}
+
+func (r TimeRange) Validate() error {
+	limit, ok := maxTimeUnitDuration[r.Unit]
+	if !ok {
+		return fmt.Errorf("invalid time unit %q", r.Unit)
+	}
+	if r.Start.IsZero() {
+		return errors.New("time range without start")
+	}
+	if r.Duration < 1 || r.Duration > limit {
+		return fmt.Errorf("time range duration %d out of range 1..%d for %s", r.Duration, limit, r.Unit)
+	}
+	return nil
+}
+
+func (r TimeRange) Query() url.Values {
+	q := url.Values{}
+	q.Set("time_unit", string(r.Unit))
+	q.Set("start", r.Start.Format(timeUnitLayouts[r.Unit]))
+	q.Set("duration", strconv.Itoa(r.Duration))
+	return q
+}
+
+type UserStats struct {
+	New    int `json:"new"`
+	Online int `json:"online"`
+	Active int `json:"active"`
+}
+
+type UserStatsItem struct {
+	Time     string     `json:"time"`
+	Android  *UserStats `json:"android,omitempty"`
+	IOS      *UserStats `json:"ios,omitempty"`
+	QuickApp *UserStats `json:"quickapp,omitempty"`
+	Hmos     *UserStats `json:"hmos,omitempty"`
+}
+
+type UsersResult struct {
+	TimeUnit TimeUnit         `json:"time_unit"`
+	Start    string           `json:"start"`
+	Duration int              `json:"duration"`
+	Items    []*UserStatsItem `json:"items"`
+}
+
+// Users reports new, online and active users per time unit.
+func (c ReportClient) Users(r TimeRange) (*UsersResult, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	link := c.url + "/v3/users?" + r.Query().Encode()
+	resp, err := c.Request("GET", link, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+	var out UsersResult
+	err = json.Unmarshal(resp.Bytes(), &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
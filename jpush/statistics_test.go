@@ -0,0 +1,59 @@
+package jpush
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeRangeQuery(t *testing.T) {
+	start := time.Date(2020, 6, 10, 9, 30, 0, 0, time.UTC)
+	cases := []struct {
+		r    TimeRange
+		want string
+	}{
+		{Hours(start, 3), "duration=3&start=2020-06-10+09&time_unit=HOUR"},
+		{Days(start, 7), "duration=7&start=2020-06-10&time_unit=DAY"},
+		{Months(start, 2), "duration=2&start=2020-06&time_unit=MONTH"},
+		{LastDays(start, 7), "duration=7&start=2020-06-03&time_unit=DAY"},
+	}
+	for _, c := range cases {
+		if err := c.r.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if got := c.r.Query().Encode(); got != c.want {
+			t.Errorf("got %s, want %s", got, c.want)
+		}
+	}
+	if Days(start, 61).Validate() == nil {
+		t.Error("expected 61 days to be rejected")
+	}
+}
+
+func TestReportMessagesDetail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/messages/detail" || r.URL.Query().Get("msg_ids") != "1,2" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[{"msg_id":67554217262909280,"details":{"notification":{
+			"android":{"target":10,"online_push":6,"received":8,"display":7,"click":2,
+				"sub_android":{"xiaomi":{"target":4,"received":3,"display":3,"click":1}}},
+			"ios":{"apns_target":5,"apns_sent":5,"apns_received":4,"apns_click":1}}}}]`))
+	}))
+	defer server.Close()
+
+	c := ReportClient{BaseClient: &BaseClient{}, url: server.URL}
+	list, err := c.MessagesDetail([]string{"1", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].MsgId != "67554217262909280" {
+		t.Fatalf("unexpected result %+v", list)
+	}
+	n := list[0].Details.Notification
+	if n.Android.Target != 10 || n.Android.Vendors["xiaomi"].Received != 3 || n.IOS.ApnsReceived != 4 {
+		t.Fatalf("unexpected stats %+v %+v", n.Android, n.IOS)
+	}
+}
@@ -0,0 +1,82 @@
+package jpush
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// MaxDeviceStatusRegistrationIds is how many registration ids DeviceStatus
+// accepts per call.
+const MaxDeviceStatusRegistrationIds = 1000
+
+// jpushLocation is the time zone JPush reports last online times in.
+var jpushLocation = time.FixedZone("CST", 8*60*60)
+
+type DeviceStatusResult struct {
+	Online bool
+	// LastOnline is when an offline device was last online; zero for
+	// online devices and devices not seen in the last days JPush keeps.
+	LastOnline time.Time
+}
+
+func (r *DeviceStatusResult) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Online         bool   `json:"online"`
+		LastOnlineTime string `json:"last_online_time"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	r.Online = raw.Online
+	r.LastOnline = time.Time{}
+	if raw.LastOnlineTime != "" {
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", raw.LastOnlineTime, jpushLocation)
+		if err != nil {
+			return err
+		}
+		r.LastOnline = t
+	}
+	return nil
+}
+
+// DeviceStatus reports whether devices are online, splitting the ids into
+// calls of MaxDeviceStatusRegistrationIds. Devices JPush answers null for
+// are left out. JPush serves it to VIP apps only.
+func (c DeviceClient) DeviceStatus(registrationIds []string) (map[string]*DeviceStatusResult, error) {
+	out := make(map[string]*DeviceStatusResult, len(registrationIds))
+	for _, regIds := range chunkStrings(registrationIds, MaxDeviceStatusRegistrationIds) {
+		params, err := c.deviceStatus(regIds)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range params {
+			if v != nil {
+				out[k] = v
+			}
+		}
+	}
+	return out, nil
+}
+
+func (c DeviceClient) deviceStatus(registrationIds []string) (map[string]*DeviceStatusResult, error) {
+	link := c.url + "/v3/devices/status/"
+	buf, err := json.Marshal(map[string][]string{"registration_ids": registrationIds})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Request("POST", link, bytes.NewReader(buf), false)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+	params := make(map[string]*DeviceStatusResult)
+	err = json.Unmarshal(resp.Bytes(), &params)
+	if err != nil {
+		return nil, err
+	}
+	return params, nil
+}
@@ -0,0 +1,72 @@
+package jpush
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sustring/push/common"
+)
+
+func TestDeviceStatus(t *testing.T) {
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3/devices/status/" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body struct {
+			RegistrationIds []string `json:"registration_ids"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		sizes = append(sizes, len(body.RegistrationIds))
+		out := make(map[string]interface{})
+		for i, regId := range body.RegistrationIds {
+			if i%2 == 0 {
+				out[regId] = map[string]interface{}{"online": true}
+			} else {
+				out[regId] = map[string]interface{}{"online": false, "last_online_time": "2014-12-16 10:57:07"}
+			}
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	defer server.Close()
+
+	ids := make([]string, 1500)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("rid%04d", i)
+	}
+	c := Client{DeviceClient: &DeviceClient{BaseClient: &BaseClient{}, url: server.URL}}
+	out, err := c.GetDeviceStatus(&common.GetDeviceStatusInput{Ids: ids})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 || sizes[0] != 1000 || sizes[1] != 500 || len(out.Devices) != 1500 {
+		t.Fatalf("unexpected chunks %v, %d devices", sizes, len(out.Devices))
+	}
+	if d := out.Devices["rid0000"]; !d.Online || !d.LastOnline.IsZero() {
+		t.Fatalf("unexpected online device %+v", d)
+	}
+	want := time.Date(2014, 12, 16, 2, 57, 7, 0, time.UTC)
+	if d := out.Devices["rid0001"]; d.Online || !d.LastOnline.Equal(want) {
+		t.Fatalf("unexpected offline device %+v", d)
+	}
+}
+
+func TestDeviceStatusNull(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"rid1":{"online":true},"rid2":null}`))
+	}))
+	defer server.Close()
+
+	c := Client{DeviceClient: &DeviceClient{BaseClient: &BaseClient{}, url: server.URL}}
+	out, err := c.GetDeviceStatus(&common.GetDeviceStatusInput{Ids: []string{"rid1", "rid2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := out.Devices["rid2"]; ok || len(out.Devices) != 1 || !out.Devices["rid1"].Online {
+		t.Fatalf("unexpected devices %+v", out.Devices)
+	}
+}
@@ -0,0 +1,119 @@
+package jpush
+
+import (
+	"errors"
+	"sort"
+)
+
+// MaxTagRegistrationIds is how many registration ids JPush accepts in each
+// of the add and remove lists of one tag update.
+const MaxTagRegistrationIds = 1000
+
+// TagUpdateChunk is the outcome of one tag update call.
+type TagUpdateChunk struct {
+	Add    []string
+	Remove []string
+	Err    error
+}
+
+// TagUpdateChunked sends payload in as many calls as needed, pairing the
+// n-th chunk of additions with the n-th chunk of removals. Every chunk is
+// attempted; the error is that of the first failed chunk.
+func (c DeviceClient) TagUpdateChunked(tag string, payload *TagUpdatePayload) ([]*TagUpdateChunk, error) {
+	if err := ValidateTag(tag); err != nil {
+		return nil, err
+	}
+	add := chunkStrings(payload.Add, MaxTagRegistrationIds)
+	remove := chunkStrings(payload.Remove, MaxTagRegistrationIds)
+	n := len(add)
+	if len(remove) > n {
+		n = len(remove)
+	}
+	if n == 0 {
+		n = 1
+	}
+
+	chunks := make([]*TagUpdateChunk, 0, n)
+	var firstErr error
+	for i := 0; i < n; i++ {
+		chunk := &TagUpdateChunk{}
+		if i < len(add) {
+			chunk.Add = add[i]
+		}
+		if i < len(remove) {
+			chunk.Remove = remove[i]
+		}
+		chunk.Err = c.tagUpdate(tag, &TagUpdatePayload{Add: chunk.Add, Remove: chunk.Remove})
+		if chunk.Err != nil && firstErr == nil {
+			firstErr = chunk.Err
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, firstErr
+}
+
+// TagMembership brings the devices carrying a tag to a desired set with as
+// few changes as possible. The current members are taken from the registry,
+// so devices tagged without going through a DeviceClient with that registry
+// are unknown to it and never removed.
+type TagMembership struct {
+	client DeviceClient
+	tag    string
+}
+
+func NewTagMembership(c DeviceClient, tag string) (*TagMembership, error) {
+	if c.Registry == nil {
+		return nil, errors.New("tag membership needs a device client with a registry")
+	}
+	return &TagMembership{client: c, tag: tag}, nil
+}
+
+// Members lists the registration ids known to carry the tag, sorted.
+func (m *TagMembership) Members() ([]string, error) {
+	return m.client.Registry.TagMembers(m.tag)
+}
+
+// Diff is the update turning the current members into desired.
+func (m *TagMembership) Diff(desired []string) (*TagUpdatePayload, error) {
+	current, err := m.Members()
+	if err != nil {
+		return nil, err
+	}
+	return diffMembers(current, desired), nil
+}
+
+// Apply sends the diff to JPush; the registry follows every chunk that
+// succeeded. Nothing is sent when the membership is already as desired.
+func (m *TagMembership) Apply(desired []string) ([]*TagUpdateChunk, error) {
+	diff, err := m.Diff(desired)
+	if err != nil {
+		return nil, err
+	}
+	if len(diff.Add) == 0 && len(diff.Remove) == 0 {
+		return nil, nil
+	}
+	return m.client.TagUpdateChunked(m.tag, diff)
+}
+
+func diffMembers(current, desired []string) *TagUpdatePayload {
+	want := make(map[string]bool, len(desired))
+	for _, regId := range desired {
+		want[regId] = true
+	}
+	have := make(map[string]bool, len(current))
+	diff := &TagUpdatePayload{}
+	for _, regId := range current {
+		have[regId] = true
+		if !want[regId] {
+			diff.Remove = append(diff.Remove, regId)
+		}
+	}
+	for regId := range want {
+		if !have[regId] {
+			diff.Add = append(diff.Add, regId)
+		}
+	}
+	sort.Strings(diff.Add)
+	sort.Strings(diff.Remove)
+	return diff
+}
@@ -0,0 +1,100 @@
+package jpush
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTagUpdateChunked(t *testing.T) {
+	var calls []TagUpdatePayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			RegistrationIds TagUpdatePayload `json:"registration_ids"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		calls = append(calls, body.RegistrationIds)
+		if len(calls) == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":7008,"message":"invalid registration id"}}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	registry := NewRegistry(NewMemoryRegistryStore())
+	c := DeviceClient{BaseClient: &BaseClient{}, url: server.URL, Registry: registry}
+	add := make([]string, 2500)
+	for i := range add {
+		add[i] = fmt.Sprintf("rid%04d", i)
+	}
+	chunks, err := c.TagUpdateChunked("vip", &TagUpdatePayload{Add: add, Remove: []string{"old"}})
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Code != 7008 {
+		t.Fatalf("expected the second chunk's error, got %v", err)
+	}
+	if len(calls) != 3 || len(chunks) != 3 {
+		t.Fatalf("expected 3 calls, got %d", len(calls))
+	}
+	if len(calls[0].Add) != 1000 || len(calls[0].Remove) != 1 || len(calls[1].Add) != 1000 || len(calls[1].Remove) != 0 || len(calls[2].Add) != 500 {
+		t.Fatalf("unexpected chunks %d/%d %d/%d %d", len(calls[0].Add), len(calls[0].Remove), len(calls[1].Add), len(calls[1].Remove), len(calls[2].Add))
+	}
+	if chunks[0].Err != nil || chunks[1].Err == nil || chunks[2].Err != nil || chunks[1].Add[0] != "rid1000" {
+		t.Fatalf("unexpected outcomes %+v", chunks)
+	}
+
+	// only the chunks that succeeded reach the registry
+	members, err := registry.TagMembers("vip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1500 {
+		t.Fatalf("expected 1500 members, got %d", len(members))
+	}
+}
+
+func TestTagMembership(t *testing.T) {
+	var calls []TagUpdatePayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			RegistrationIds TagUpdatePayload `json:"registration_ids"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		calls = append(calls, body.RegistrationIds)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewTagMembership(DeviceClient{}, "vip"); err == nil {
+		t.Fatal("expected an error without registry")
+	}
+	c := DeviceClient{BaseClient: &BaseClient{}, url: server.URL, Registry: NewRegistry(NewMemoryRegistryStore())}
+	if err := c.TagUpdate("vip", &TagUpdatePayload{Add: []string{"a", "b", "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewTagMembership(c, "vip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := m.Apply([]string{"c", "d", "b"})
+	if err != nil || len(chunks) != 1 {
+		t.Fatalf("unexpected apply %v %v", chunks, err)
+	}
+	last := calls[len(calls)-1]
+	if strings.Join(last.Add, ",") != "d" || strings.Join(last.Remove, ",") != "a" {
+		t.Fatalf("unexpected diff %+v", last)
+	}
+	members, _ := m.Members()
+	if strings.Join(members, ",") != "b,c,d" {
+		t.Fatalf("unexpected members %v", members)
+	}
+
+	// already as desired: nothing is sent
+	n := len(calls)
+	if chunks, err := m.Apply([]string{"b", "c", "d"}); err != nil || chunks != nil || len(calls) != n {
+		t.Fatalf("unexpected calls for an unchanged membership")
+	}
+}
@@ -0,0 +1,296 @@
+package jpush
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	MaxAudienceTags            = 20
+	MaxAudienceAliases         = 1000
+	MaxAudienceRegistrationIds = 1000
+
+	MaxAndroidNotificationBytes = 4000
+	MaxIOSNotificationBytes     = 2048
+
+	MinAndroidPriority = -2
+	MaxAndroidPriority = 2
+
+	MaxTimeToLive = 864000 // 10 days, in seconds
+
+	MaxSmsDelayTime = 86400 // seconds
+)
+
+const (
+	AndroidStyleDefault = 0
+	AndroidStyleBigText = 1
+	AndroidStyleInbox   = 2
+	AndroidStyleBigPic  = 3
+)
+
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	list := make([]string, 0, len(e))
+	for _, v := range e {
+		list = append(list, v.Error())
+	}
+	return strings.Join(list, "; ")
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Validate checks the payload against JPush's documented limits without
+// calling the API. All violations are returned together as ValidationErrors.
+func (p *PushPayload) Validate() error {
+	v := &validator{}
+	p.validatePlatform(v)
+	p.validateAudience(v)
+	p.validateNotification(v)
+	p.validateMessage(v)
+	p.validateSmsMessage(v)
+	p.validateOptions(v)
+	return v.err()
+}
+
+func (p *PushPayload) validatePlatform(v *validator) {
+	if len(p.Platform) == 0 {
+		v.add("platform", "is required")
+		return
+	}
+	for _, platform := range p.Platform {
+		switch platform {
+		case PlatformAll, PlatformAndroid, PlatformIOS, PlatformWinPhone, PlatformQuickApp, PlatformHmos:
+		default:
+			v.add("platform", "unknown platform %q", platform)
+			return
+		}
+	}
+	if p.Notification == nil {
+		return
+	}
+	if p.Notification.Android != nil && !p.targets(PlatformAndroid) {
+		v.add("notification.android", "set but platform %v does not include android", p.Platform)
+	}
+	if p.Notification.IOS != nil && !p.targets(PlatformIOS) {
+		v.add("notification.ios", "set but platform %v does not include ios", p.Platform)
+	}
+	if p.Notification.Hmos != nil && !p.targets(PlatformHmos) {
+		v.add("notification.hmos", "set but platform %v does not include hmos", p.Platform)
+	}
+	if p.Notification.QuickApp != nil && !p.targets(PlatformQuickApp) {
+		v.add("notification.quickapp", "set but platform %v does not include quickapp", p.Platform)
+	}
+}
+
+func (p *PushPayload) targets(platform Platform) bool {
+	return p.Platform.Has(platform)
+}
+
+func (p *PushPayload) validateAudience(v *validator) {
+	a := p.Audience
+	if a == nil {
+		v.add("audience", "is required")
+		return
+	}
+	if len(a.Tag)+len(a.TagAnd)+len(a.TagNot)+len(a.Alias)+len(a.RegistrationId)+len(a.Segment)+len(a.ABTest) == 0 && a.LiveActivityId == "" {
+		v.add("audience", "has no target")
+	}
+	if len(a.Tag) > MaxAudienceTags {
+		v.add("audience.tag", "has %d entries, max %d", len(a.Tag), MaxAudienceTags)
+	}
+	if len(a.TagAnd) > MaxAudienceTags {
+		v.add("audience.tag_and", "has %d entries, max %d", len(a.TagAnd), MaxAudienceTags)
+	}
+	if len(a.TagNot) > MaxAudienceTags {
+		v.add("audience.tag_not", "has %d entries, max %d", len(a.TagNot), MaxAudienceTags)
+	}
+	if len(a.Alias) > MaxAudienceAliases {
+		v.add("audience.alias", "has %d entries, max %d", len(a.Alias), MaxAudienceAliases)
+	}
+	if len(a.RegistrationId) > MaxAudienceRegistrationIds {
+		v.add("audience.registration_id", "has %d entries, max %d", len(a.RegistrationId), MaxAudienceRegistrationIds)
+	}
+}
+
+func (p *PushPayload) validateNotification(v *validator) {
+	if p.LiveActivity != nil {
+		p.validateLiveActivity(v)
+		return
+	}
+	if p.Notification == nil && p.Message == nil {
+		v.add("notification", "either notification or message is required")
+		return
+	}
+	n := p.Notification
+	if n == nil {
+		return
+	}
+	if n.Alert == "" && n.Android == nil && n.IOS == nil && n.Hmos == nil && n.QuickApp == nil {
+		v.add("notification", "has no alert")
+	}
+	if n.Android != nil {
+		validateNotificationAndroid(v, n.Android)
+	}
+	if n.IOS != nil && (n.IOS.RelevanceScore < 0 || n.IOS.RelevanceScore > 1) {
+		v.add("notification.ios.relevance-score", "%v out of range 0..1", n.IOS.RelevanceScore)
+	}
+	if n.Hmos != nil && n.Hmos.Category == "" {
+		v.add("notification.hmos.category", "is required")
+	}
+	if n.QuickApp != nil {
+		n.QuickApp.validateTo(v)
+	}
+	if size := p.PayloadSize(PlatformAndroid); size > MaxAndroidNotificationBytes {
+		v.add("notification.android", "is %d bytes, max %d", size, MaxAndroidNotificationBytes)
+	}
+	if size := p.PayloadSize(PlatformIOS); size > MaxIOSNotificationBytes {
+		v.add("notification.ios", "is %d bytes, max %d", size, MaxIOSNotificationBytes)
+	}
+}
+
+func (n *NotificationQuickApp) validateTo(v *validator) {
+	if n.Title == "" {
+		v.add("notification.quickapp.title", "is required")
+	}
+	if n.Page == "" {
+		v.add("notification.quickapp.page", "is required")
+	}
+}
+
+// validate returns the first missing field, for building a payload.
+func (n *NotificationQuickApp) validate() error {
+	v := &validator{}
+	n.validateTo(v)
+	if len(v.errs) > 0 {
+		return v.errs[0]
+	}
+	return nil
+}
+
+func validateNotificationAndroid(v *validator, n *NotificationAndroid) {
+	if n.Priority < MinAndroidPriority || n.Priority > MaxAndroidPriority {
+		v.add("notification.android.priority", "%d out of range %d..%d", n.Priority, MinAndroidPriority, MaxAndroidPriority)
+	}
+	switch n.Style {
+	case AndroidStyleDefault:
+	case AndroidStyleBigText:
+		if n.BigText == "" {
+			v.add("notification.android.big_text", "is required for style %d", n.Style)
+		}
+	case AndroidStyleInbox:
+		if len(n.Inbox) == 0 {
+			v.add("notification.android.inbox", "is required for style %d", n.Style)
+		}
+	case AndroidStyleBigPic:
+		if n.BigPicPath == "" {
+			v.add("notification.android.big_pic_path", "is required for style %d", n.Style)
+		}
+	default:
+		v.add("notification.android.style", "unknown style %d", n.Style)
+	}
+}
+
+func (p *PushPayload) validateLiveActivity(v *validator) {
+	if p.Notification != nil || p.Message != nil {
+		v.add("live_activity", "cannot be combined with notification or message")
+	}
+	if len(p.Platform) != 1 || p.Platform[0] != PlatformIOS {
+		v.add("live_activity", "requires platform ios only")
+	}
+	if p.Audience != nil && p.Audience.LiveActivityId == "" {
+		v.add("audience.live_activity_id", "is required for live_activity")
+	}
+	la := p.LiveActivity.IOS
+	if la == nil {
+		v.add("live_activity.ios", "is required")
+		return
+	}
+	switch la.Event {
+	case LiveActivityStart:
+		if la.AttributesType == "" {
+			v.add("live_activity.ios.attributes-type", "is required for event %q", la.Event)
+		}
+	case LiveActivityUpdate, LiveActivityEnd:
+	default:
+		v.add("live_activity.ios.event", "unknown event %q", la.Event)
+	}
+	if la.ContentState == nil {
+		v.add("live_activity.ios.content-state", "is required")
+	}
+}
+
+func (p *PushPayload) validateMessage(v *validator) {
+	if p.Message != nil && p.Message.MsgContent == "" {
+		v.add("message.msg_content", "is required")
+	}
+}
+
+func (p *PushPayload) validateSmsMessage(v *validator) {
+	sms := p.SmsMessage
+	if sms == nil {
+		return
+	}
+	if sms.TempId == 0 && sms.Content == "" {
+		v.add("sms_message.temp_id", "is required")
+	}
+	if sms.DelayTime < 0 || sms.DelayTime > MaxSmsDelayTime {
+		v.add("sms_message.delay_time", "%d out of range 0..%d", sms.DelayTime, MaxSmsDelayTime)
+	}
+}
+
+func (p *PushPayload) validateOptions(v *validator) {
+	if p.Options == nil {
+		return
+	}
+	if p.Options.TimeToLive < 0 || p.Options.TimeToLive > MaxTimeToLive {
+		v.add("options.time_to_live", "%d out of range 0..%d", p.Options.TimeToLive, MaxTimeToLive)
+	}
+}
+
+// withAlert returns a copy carrying the notification-level alert when the
+// platform alert is empty, which is what JPush delivers to the device.
+func (n NotificationAndroid) withAlert(alert string) *NotificationAndroid {
+	if n.Alert == "" {
+		n.Alert = alert
+	}
+	return &n
+}
+
+func (n NotificationIOS) withAlert(alert string) *NotificationIOS {
+	if n.Alert == nil || n.Alert == "" {
+		n.Alert = alert
+	}
+	return &n
+}
+
+func jsonSize(v interface{}) int {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(buf)
+}
@@ -0,0 +1,156 @@
+package jpush
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPushPayloadValidate(t *testing.T) {
+	payload := &PushPayload{
+		Platform: NewPlatforms(PlatformAndroid),
+		Audience: &Audience{Alias: []string{"qiuqiankun"}},
+		Notification: &Notification{
+			Android: &NotificationAndroid{Alert: "hello"},
+		},
+	}
+	if err := payload.Validate(); err != nil {
+		t.Fatalf("valid payload: %v", err)
+	}
+}
+
+func TestPushPayloadValidateCollectsAll(t *testing.T) {
+	payload := &PushPayload{
+		Platform: NewPlatforms(PlatformAndroid),
+		Audience: &Audience{Tag: make([]string, MaxAudienceTags+1)},
+		Notification: &Notification{
+			Android: &NotificationAndroid{
+				Alert:    strings.Repeat("a", MaxAndroidNotificationBytes),
+				Priority: 3,
+				Style:    AndroidStyleInbox,
+			},
+			IOS: &NotificationIOS{Alert: "hello"},
+		},
+		Options: &PushOptions{TimeToLive: MaxTimeToLive + 1},
+	}
+	err := payload.Validate()
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %T", err)
+	}
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	for _, field := range []string{
+		"notification.ios",
+		"audience.tag",
+		"notification.android",
+		"notification.android.priority",
+		"notification.android.inbox",
+		"options.time_to_live",
+	} {
+		if !fields[field] {
+			t.Errorf("missing violation for %s in %v", field, err)
+		}
+	}
+}
+
+func TestPushPayloadValidateQuickApp(t *testing.T) {
+	payload := &PushPayload{
+		Platform: NewPlatforms(PlatformAndroid),
+		Audience: &Audience{Alias: []string{"qiuqiankun"}},
+		Notification: &Notification{
+			QuickApp: &NotificationQuickApp{Alert: "hello"},
+		},
+	}
+	errs, ok := payload.Validate().(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %v", payload.Validate())
+	}
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	for _, field := range []string{
+		"notification.quickapp",
+		"notification.quickapp.title",
+		"notification.quickapp.page",
+	} {
+		if !fields[field] {
+			t.Errorf("missing violation for %s in %v", field, errs)
+		}
+	}
+}
+
+func TestAndroidStyleBuildersValidate(t *testing.T) {
+	for _, n := range []*NotificationAndroid{
+		NewAndroidNotification("hello").WithBigText("a long text"),
+		NewAndroidNotification("hello").WithInbox("first", "second"),
+		NewAndroidNotification("hello").WithBigPicture("https://example.com/a.png"),
+	} {
+		payload := &PushPayload{
+			Platform:     NewPlatforms(PlatformAndroid),
+			Audience:     &Audience{Alias: []string{"qiuqiankun"}},
+			Notification: &Notification{Android: n.WithAlertType(AlertSound | AlertVibrate)},
+		}
+		if err := payload.Validate(); err != nil {
+			t.Errorf("style %d: %v", n.Style, err)
+		}
+	}
+}
+
+func TestLiveActivityPayload(t *testing.T) {
+	payload := liveActivityPayload("la-1", &LiveActivityIOS{
+		Event:        LiveActivityUpdate,
+		ContentState: map[string]interface{}{"eta": 5},
+	})
+	if err := payload.Validate(); err != nil {
+		t.Fatalf("valid live activity payload: %v", err)
+	}
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"platform":["ios"],"audience":{"live_activity_id":"la-1"},"live_activity":{"ios":{"event":"update","content-state":{"eta":5}}}}`
+	if string(buf) != want {
+		t.Fatalf("got %s, want %s", buf, want)
+	}
+
+	payload.Audience.LiveActivityId = ""
+	if err := payload.Validate(); err == nil || !strings.Contains(err.Error(), "audience.live_activity_id") {
+		t.Fatalf("expected missing live activity id, got %v", err)
+	}
+}
+
+func TestSmsMessageValidate(t *testing.T) {
+	cases := []struct {
+		sms   *SmsMessage
+		field string
+	}{
+		{&SmsMessage{TempId: 1001, DelayTime: MaxSmsDelayTime}, ""},
+		{&SmsMessage{Content: "legacy"}, ""},
+		{&SmsMessage{DelayTime: 60}, "sms_message.temp_id"},
+		{&SmsMessage{TempId: 1001, DelayTime: MaxSmsDelayTime + 1}, "sms_message.delay_time"},
+		{&SmsMessage{TempId: 1001, DelayTime: -1}, "sms_message.delay_time"},
+	}
+	for _, c := range cases {
+		payload := &PushPayload{
+			Platform:     NewPlatforms(PlatformAndroid),
+			Audience:     &Audience{Alias: []string{"qiuqiankun"}},
+			Notification: &Notification{Android: &NotificationAndroid{Alert: "hello"}},
+			SmsMessage:   c.sms,
+		}
+		err := payload.Validate()
+		if c.field == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected error %v", c.sms, err)
+			}
+			continue
+		}
+		errs, ok := err.(ValidationErrors)
+		if !ok || len(errs) != 1 || errs[0].Field != c.field {
+			t.Errorf("%+v: expected a violation for %s, got %v", c.sms, c.field, err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Store holds the templates loaded from a directory. It is safe for
+// concurrent use, and Reload swaps the whole set at once so a render never
+// sees a half-loaded directory.
+type Store struct {
+	dir string
+
+	mu        sync.RWMutex
+	templates map[string]*Template
+	signature string
+}
+
+func NewStore(dir string) (*Store, error) {
+	s := &Store{dir: dir}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Get(name string) (*Template, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.templates[name]
+	return t, ok
+}
+
+func (s *Store) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]string, 0, len(s.templates))
+	for name := range s.templates {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (s *Store) Render(name string, vars map[string]interface{}) (*Rendered, error) {
+	t, ok := s.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("template %s: not found", name)
+	}
+	return t.Render(vars)
+}
+
+// Reload reads every .json, .yaml and .yml file in the directory. On error
+// the previously loaded templates stay in place.
+func (s *Store) Reload() error {
+	files, signature, err := s.scan()
+	if err != nil {
+		return err
+	}
+	templates := make(map[string]*Template, len(files))
+	for _, file := range files {
+		t, err := loadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, ok := templates[t.Name]; ok {
+			return fmt.Errorf("template %s: defined twice, again in %s", t.Name, file)
+		}
+		templates[t.Name] = t
+	}
+
+	s.mu.Lock()
+	s.templates = templates
+	s.signature = signature
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch polls the directory every interval and reloads it when a file was
+// added, removed or modified. Reload errors go to onError, if set. Call the
+// returned function to stop watching.
+func (s *Store) Watch(interval time.Duration, onError func(error)) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			_, signature, err := s.scan()
+			if err == nil {
+				s.mu.RLock()
+				changed := signature != s.signature
+				s.mu.RUnlock()
+				if !changed {
+					continue
+				}
+				err = s.Reload()
+			}
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// scan lists the template files and a signature of their names, sizes and
+// modification times, which changes whenever the directory content does.
+func (s *Store) scan() ([]string, string, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, "", err
+	}
+	var files []string
+	var signature strings.Builder
+	for _, info := range infos {
+		if info.IsDir() || !isTemplateFile(info.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(s.dir, info.Name()))
+		fmt.Fprintf(&signature, "%s:%d:%d;", info.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return files, signature.String(), nil
+}
+
+func isTemplateFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+func loadFile(file string) (*Template, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	t := &Template{}
+	if strings.ToLower(filepath.Ext(file)) == ".json" {
+		err = json.Unmarshal(buf, t)
+	} else {
+		err = yaml.Unmarshal(buf, t)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if err := t.Parse(); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return t, nil
+}
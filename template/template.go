@@ -0,0 +1,238 @@
+// Package template renders named push templates with per-user variables.
+//
+// Templates are JSON or YAML files in a directory, one template per file:
+//
+//	name: order_shipped
+//	title: "Order {{.order_id}} shipped"
+//	alert: "Hi {{.name}}, your parcel is on its way."
+//	extras:
+//	  order_id: "{{.order_id}}"
+//	platform: [android, ios]
+//	required: [name, order_id]
+//
+// Title, alert and extras values are text/template strings.
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/sustring/push/common"
+	"github.com/sustring/push/jpush"
+)
+
+type Template struct {
+	Name         string            `json:"name" yaml:"name"`
+	Title        string            `json:"title" yaml:"title"`
+	Alert        string            `json:"alert" yaml:"alert"`
+	Extras       map[string]string `json:"extras" yaml:"extras"`
+	Platform     []string          `json:"platform" yaml:"platform"` // android, ios, quickapp, hmos; empty or just all for all
+	Category     string            `json:"category" yaml:"category"` // transactional, social, marketing, system
+	HmosCategory string            `json:"hmos_category" yaml:"hmos_category"`
+	Message      bool              `json:"message" yaml:"message"` // send as custom message instead of notification
+	Required     []string          `json:"required" yaml:"required"`
+
+	title  *texttemplate.Template
+	alert  *texttemplate.Template
+	extras map[string]*texttemplate.Template
+}
+
+var categories = map[string]common.NotificationCategory{
+	"":              common.CategoryDefault,
+	"transactional": common.CategoryTransactional,
+	"social":        common.CategorySocial,
+	"marketing":     common.CategoryMarketing,
+	"system":        common.CategorySystem,
+}
+
+var platforms = map[string]common.PlatformType{
+	"all":      common.ALL,
+	"android":  common.Android,
+	"ios":      common.IOS,
+	"quickapp": common.QuickApp,
+	"hmos":     common.Hmos,
+}
+
+// Parse compiles the title, alert and extras and checks the options. Render
+// requires it, and as it changes the template, it must run before the
+// template is shared; Store parses every template it loads.
+func (t *Template) Parse() error {
+	if t.Name == "" {
+		return fmt.Errorf("template: missing name")
+	}
+	if t.Alert == "" {
+		return fmt.Errorf("template %s: missing alert", t.Name)
+	}
+	if _, ok := categories[t.Category]; !ok {
+		return fmt.Errorf("template %s: unknown category %q", t.Name, t.Category)
+	}
+	for _, p := range t.Platform {
+		if _, ok := platforms[p]; !ok {
+			return fmt.Errorf("template %s: unknown platform %q", t.Name, p)
+		}
+		if p == "all" && len(t.Platform) > 1 {
+			return fmt.Errorf("template %s: platform all cannot be combined with others", t.Name)
+		}
+	}
+
+	title, err := parse(t.Name+".title", t.Title)
+	if err != nil {
+		return err
+	}
+	alert, err := parse(t.Name+".alert", t.Alert)
+	if err != nil {
+		return err
+	}
+	extras := make(map[string]*texttemplate.Template, len(t.Extras))
+	for k, v := range t.Extras {
+		if extras[k], err = parse(t.Name+".extras."+k, v); err != nil {
+			return err
+		}
+	}
+	t.title, t.alert, t.extras = title, alert, extras
+	return nil
+}
+
+func parse(name, text string) (*texttemplate.Template, error) {
+	return texttemplate.New(name).Option("missingkey=error").Parse(text)
+}
+
+// MissingVariablesError lists the required variables absent from a render.
+type MissingVariablesError struct {
+	Template  string
+	Variables []string
+}
+
+func (e *MissingVariablesError) Error() string {
+	return fmt.Sprintf("template %s: missing variables %s", e.Template, strings.Join(e.Variables, ", "))
+}
+
+// Rendered is a template filled in for one recipient.
+type Rendered struct {
+	Title        string
+	Alert        string
+	Extras       map[string]string
+	Platform     common.PlatformType
+	Category     common.NotificationCategory
+	HmosCategory string
+	Message      bool
+}
+
+// Render fills the template with vars. Every required variable must be
+// present and non-empty. The template must have been parsed.
+func (t *Template) Render(vars map[string]interface{}) (*Rendered, error) {
+	if t.alert == nil {
+		return nil, fmt.Errorf("template %s: not parsed", t.Name)
+	}
+	var missing []string
+	for _, name := range t.Required {
+		if v, ok := vars[name]; !ok || v == nil || v == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, &MissingVariablesError{Template: t.Name, Variables: missing}
+	}
+
+	out := &Rendered{
+		Extras:       make(map[string]string, len(t.extras)),
+		Category:     categories[t.Category],
+		HmosCategory: t.HmosCategory,
+		Message:      t.Message,
+	}
+	for _, p := range t.Platform {
+		out.Platform |= platforms[p]
+	}
+	var err error
+	if out.Title, err = execute(t.title, vars); err != nil {
+		return nil, err
+	}
+	if out.Alert, err = execute(t.alert, vars); err != nil {
+		return nil, err
+	}
+	for k, tmpl := range t.extras {
+		if out.Extras[k], err = execute(tmpl, vars); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func execute(t *texttemplate.Template, vars map[string]interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// PushMessageInput maps the rendered template onto the provider-neutral
+// message. Extras have no place in the common model and are dropped; use
+// PushPayload when they matter.
+func (r *Rendered) PushMessageInput(id int64, msgType string, audience common.AudienceInfo) *common.PushMessageInput {
+	in := &common.PushMessageInput{
+		Platform:     r.Platform,
+		Id:           id,
+		Type:         msgType,
+		Alert:        r.Alert,
+		Audience:     audience,
+		Presentation: !r.Message,
+		HmosCategory: r.HmosCategory,
+		Category:     r.Category,
+	}
+	if r.Title != "" {
+		in.Rich = &common.RichContent{Title: r.Title}
+	}
+	return in
+}
+
+// PushPayload builds a JPush payload carrying the title, alert and extras
+// for every platform in the template.
+func (r *Rendered) PushPayload(audience *jpush.Audience) *jpush.PushPayload {
+	extras := make(map[string]interface{}, len(r.Extras))
+	for k, v := range r.Extras {
+		extras[k] = v
+	}
+	payload := &jpush.PushPayload{
+		Platform: jpush.NewPlatforms(jpush.PlatformAll),
+		Audience: audience,
+	}
+	if r.Platform != common.ALL {
+		payload.Platform = nil
+		for name, p := range platforms {
+			if p != common.ALL && r.Platform&p != 0 {
+				payload.Platform = append(payload.Platform, jpush.Platform(name))
+			}
+		}
+		sort.Slice(payload.Platform, func(i, j int) bool { return payload.Platform[i] < payload.Platform[j] })
+	}
+
+	if r.Message {
+		payload.Message = &jpush.Message{MsgContent: r.Alert, Title: r.Title, Extras: extras}
+		return payload
+	}
+	n := &jpush.Notification{}
+	if r.Platform.Has(common.Android) {
+		n.Android = jpush.NewAndroidNotification(r.Alert).WithTitle(r.Title).WithExtras(extras)
+	}
+	if r.Platform.Has(common.IOS) {
+		var alert interface{} = r.Alert
+		if r.Title != "" {
+			alert = &jpush.IOSAlert{Title: r.Title, Body: r.Alert}
+		}
+		n.IOS = jpush.NewIOSNotification(alert)
+		n.IOS.Extras = extras
+	}
+	if r.Platform.Has(common.Hmos) && (r.Platform != common.ALL || r.HmosCategory != "") {
+		n.Hmos = &jpush.NotificationHmos{Alert: r.Alert, Title: r.Title, Category: r.HmosCategory, Extras: extras}
+	}
+	if r.Platform.Has(common.QuickApp) {
+		n.Alert = r.Alert
+	}
+	payload.Notification = n
+	return payload
+}
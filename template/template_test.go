@@ -0,0 +1,188 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sustring/push/common"
+	"github.com/sustring/push/jpush"
+)
+
+const shippedYAML = `name: order_shipped
+title: "Order {{.order_id}} shipped"
+alert: "Hi {{.name}}, your parcel is on its way."
+extras:
+  order_id: "{{.order_id}}"
+platform: [android, ios]
+category: transactional
+required: [name, order_id]
+`
+
+func newTestStore(t *testing.T) (*Store, string) {
+	dir, err := ioutil.TempDir("", "push-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "shipped.yaml"), []byte(shippedYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	json := `{"name": "welcome", "alert": "Welcome {{.name}}"}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "welcome.json"), []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+func TestStoreRender(t *testing.T) {
+	s, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	r, err := s.Render("order_shipped", map[string]interface{}{"name": "Kitty", "order_id": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Title != "Order 42 shipped" || r.Alert != "Hi Kitty, your parcel is on its way." || r.Extras["order_id"] != "42" {
+		t.Fatalf("unexpected render %+v", r)
+	}
+
+	in := r.PushMessageInput(1, "order", common.AudienceInfo{AliasList: []string{"kitty"}})
+	if in.Platform != common.Android|common.IOS || in.Category != common.CategoryTransactional || !in.Presentation {
+		t.Fatalf("unexpected input %+v", in)
+	}
+
+	payload := r.PushPayload(&jpush.Audience{Alias: []string{"kitty"}})
+	if err := payload.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Notification.Android.Extras["order_id"] != "42" {
+		t.Fatalf("missing extras in %+v", payload.Notification.Android)
+	}
+}
+
+func TestRenderMissingVariables(t *testing.T) {
+	s, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	_, err := s.Render("order_shipped", map[string]interface{}{"name": "Kitty"})
+	missing, ok := err.(*MissingVariablesError)
+	if !ok || len(missing.Variables) != 1 || missing.Variables[0] != "order_id" {
+		t.Fatalf("expected missing order_id, got %v", err)
+	}
+
+	// variables used but not declared required still fail instead of
+	// rendering "<no value>"
+	if _, err := s.Render("welcome", map[string]interface{}{}); err == nil {
+		t.Fatal("expected error for undeclared missing variable")
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	s, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("alert: \"{{.name\""), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload(); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if _, ok := s.Get("welcome"); !ok {
+		t.Fatal("failed reload dropped the loaded templates")
+	}
+
+	if err := os.Remove(filepath.Join(dir, "broken.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "welcome.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if names := s.Names(); len(names) != 1 || names[0] != "order_shipped" {
+		t.Fatalf("got templates %v", names)
+	}
+}
+
+func TestRenderUnparsed(t *testing.T) {
+	tmpl := &Template{Name: "welcome", Alert: "Welcome {{.name}}"}
+	if _, err := tmpl.Render(map[string]interface{}{"name": "Kitty"}); err == nil {
+		t.Fatal("expected an error for an unparsed template")
+	}
+	if err := tmpl.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := tmpl.Render(map[string]interface{}{"name": "Kitty"}); err != nil || r.Alert != "Welcome Kitty" {
+		t.Fatalf("unexpected render %+v, %v", r, err)
+	}
+
+	// a failed parse leaves a parsed template as it was
+	tmpl.Alert = "{{.name"
+	if err := tmpl.Parse(); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if r, err := tmpl.Render(map[string]interface{}{"name": "Kitty"}); err != nil || r.Alert != "Welcome Kitty" {
+		t.Fatalf("unexpected render %+v, %v", r, err)
+	}
+}
+
+func TestParsePlatformAll(t *testing.T) {
+	for _, platform := range [][]string{{"all", "android"}, {"ios", "all"}} {
+		tmpl := &Template{Name: "welcome", Alert: "Welcome", Platform: platform}
+		if err := tmpl.Parse(); err == nil {
+			t.Errorf("expected an error for platform %v", platform)
+		}
+	}
+	tmpl := &Template{Name: "welcome", Alert: "Welcome", Platform: []string{"all"}}
+	if err := tmpl.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := tmpl.Render(nil); err != nil || r.Platform != common.ALL {
+		t.Fatalf("unexpected render %+v, %v", r, err)
+	}
+}
+
+func TestStoreWatch(t *testing.T) {
+	s, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	errs := make(chan error, 1)
+	stop := s.Watch(10*time.Millisecond, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	defer stop()
+
+	json := `{"name": "welcome", "alert": "Welcome back, {{.name}}"}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "welcome.json"), []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r, err := s.Render("welcome", map[string]interface{}{"name": "Kitty"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Alert == "Welcome back, Kitty" {
+			break
+		}
+		select {
+		case err := <-errs:
+			t.Fatal(err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rewrite not picked up, still rendering %q", r.Alert)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
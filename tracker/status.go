@@ -0,0 +1,119 @@
+package tracker
+
+import (
+	"time"
+
+	"github.com/sustring/push/jpush"
+)
+
+type MessageStatus struct {
+	Id       string
+	MsgIds   []string
+	Created  time.Time
+	LastPoll time.Time
+	NextPoll time.Time
+	Attempts int
+	Done     bool // no longer polled
+
+	Reports map[string]*jpush.ReceivedDetailResult // latest report by msg id
+	Devices map[string]*DeviceStatus               // by registration id
+}
+
+// DeviceStatus is what is known about one device, from callbacks and the
+// MessageStatus API.
+type DeviceStatus struct {
+	Platform       jpush.Platform
+	Sent           bool
+	Received       bool
+	Clicked        bool
+	DeliveryStatus jpush.DeliveryStatus
+}
+
+type PlatformStatus struct {
+	Sent     int
+	Received int
+	Clicked  int
+}
+
+// Platforms summarizes the message per platform. Report counters and
+// callback counts overlap, so each figure is the larger of the two rather
+// than their sum.
+func (s *MessageStatus) Platforms() map[jpush.Platform]*PlatformStatus {
+	out := make(map[jpush.Platform]*PlatformStatus)
+	get := func(p jpush.Platform) *PlatformStatus {
+		if out[p] == nil {
+			out[p] = &PlatformStatus{}
+		}
+		return out[p]
+	}
+
+	report := make(map[jpush.Platform]*PlatformStatus)
+	add := func(p jpush.Platform, sent, received int) {
+		if sent == 0 && received == 0 {
+			return
+		}
+		if report[p] == nil {
+			report[p] = &PlatformStatus{}
+		}
+		report[p].Sent += sent
+		report[p].Received += received
+	}
+	for _, r := range s.Reports {
+		add(jpush.PlatformAndroid, r.AndroidPNSSent+r.JPushReceived, r.JPushReceived)
+		add(jpush.PlatformIOS, r.IOSAPNSSent, r.IOSAPNSReceived)
+		add(jpush.PlatformQuickApp, r.QuickappPNSSent+r.QuickappJpushReceived, r.QuickappJpushReceived)
+	}
+
+	callback := make(map[jpush.Platform]*PlatformStatus)
+	for _, d := range s.Devices {
+		if d.Platform == "" {
+			continue
+		}
+		c := callback[d.Platform]
+		if c == nil {
+			c = &PlatformStatus{}
+			callback[d.Platform] = c
+		}
+		if d.Sent {
+			c.Sent++
+		}
+		if d.Received {
+			c.Received++
+		}
+		if d.Clicked {
+			c.Clicked++
+		}
+	}
+
+	for p, r := range report {
+		*get(p) = *r
+	}
+	for p, c := range callback {
+		o := get(p)
+		o.Sent = max(o.Sent, c.Sent)
+		o.Received = max(o.Received, c.Received)
+		o.Clicked = max(o.Clicked, c.Clicked)
+	}
+	return out
+}
+
+// delivered reports whether every known device received the message, which
+// ends polling early.
+func (s *MessageStatus) delivered() bool {
+	if len(s.Devices) == 0 {
+		return false
+	}
+	for _, d := range s.Devices {
+		if !d.Received && d.DeliveryStatus != jpush.MessageDelivered {
+			return false
+		}
+	}
+	return true
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,194 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("tracker: message not found")
+
+// Store persists message statuses. Get and GetByMsgId return ErrNotFound for
+// unknown ids. Implementations must return copies, as the tracker modifies
+// what it reads before saving it back.
+type Store interface {
+	Get(id string) (*MessageStatus, error)
+	GetByMsgId(msgId string) (*MessageStatus, error)
+	List() ([]*MessageStatus, error)
+	Save(s *MessageStatus) error
+}
+
+// MemoryStore keeps statuses in memory only.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	items  map[string][]byte
+	msgIds map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items:  make(map[string][]byte),
+		msgIds: make(map[string]string),
+	}
+}
+
+func (m *MemoryStore) Get(id string) (*MessageStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.get(id)
+}
+
+func (m *MemoryStore) get(id string) (*MessageStatus, error) {
+	buf, ok := m.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var s MessageStatus
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (m *MemoryStore) GetByMsgId(msgId string) (*MessageStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	id, ok := m.msgIds[msgId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return m.get(id)
+}
+
+func (m *MemoryStore) List() ([]*MessageStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]*MessageStatus, 0, len(m.items))
+	for id := range m.items {
+		s, err := m.get(id)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func (m *MemoryStore) Save(s *MessageStatus) error {
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[s.Id] = buf
+	for _, msgId := range s.MsgIds {
+		m.msgIds[msgId] = s.Id
+	}
+	return nil
+}
+
+// Prune removes the done statuses created before before and returns how
+// many it removed.
+func (m *MemoryStore) Prune(before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id := range m.items {
+		s, err := m.get(id)
+		if err != nil {
+			return n, err
+		}
+		if !s.Done || !s.Created.Before(before) {
+			continue
+		}
+		delete(m.items, id)
+		for _, msgId := range s.MsgIds {
+			if m.msgIds[msgId] == id {
+				delete(m.msgIds, msgId)
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+// FileStore is a MemoryStore written to a JSON file after every save, so
+// tracking resumes after a restart. As every save rewrites the whole file,
+// long running trackers should set Config.Retain to prune done messages.
+type FileStore struct {
+	*MemoryStore
+	path string
+	mu   sync.Mutex // orders file writes
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	f := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*MessageStatus
+	if err := json.Unmarshal(buf, &list); err != nil {
+		return nil, err
+	}
+	for _, s := range list {
+		if err := f.MemoryStore.Save(s); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *FileStore) Save(s *MessageStatus) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.MemoryStore.Save(s); err != nil {
+		return err
+	}
+	return f.write()
+}
+
+// Prune removes the done statuses created before before, and rewrites the
+// file when it removed any.
+func (f *FileStore) Prune(before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.MemoryStore.Prune(before)
+	if err != nil || n == 0 {
+		return n, err
+	}
+	return n, f.write()
+}
+
+func (f *FileStore) write() error {
+	list, err := f.List()
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
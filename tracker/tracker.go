@@ -0,0 +1,438 @@
+// Package tracker follows pushed messages until they are delivered. It
+// merges JPush report polling with delivery callbacks into one status per
+// message and publishes every change as an event.
+package tracker
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sustring/push/common"
+	"github.com/sustring/push/jpush"
+)
+
+// MaxReceivedDetailIds is how many msg ids ReceivedDetail accepts per call.
+const MaxReceivedDetailIds = 100
+
+// Reporter is the part of jpush.ReportClient the tracker polls.
+type Reporter interface {
+	ReceivedDetail(msgIds []string) ([]*jpush.ReceivedDetailResult, error)
+	MessageStatus(payload *jpush.MessageStatusPayload) (map[string]*jpush.MessageStatusResult, error)
+}
+
+type Config struct {
+	Initial time.Duration // first poll after tracking, default 1 minute
+	Max     time.Duration // longest wait between polls, default 1 hour
+	Expire  time.Duration // stop polling after, default 24 hours
+	Buffer  int           // event channel size, default 256
+	// Retain is how long after their creation done messages are kept; Poll
+	// prunes older ones from stores that support it, such as MemoryStore
+	// and FileStore. Zero keeps them forever.
+	Retain time.Duration
+	// Registry, when set, marks the devices MessageStatus reports as
+	// invalid registration ids ineligible.
+	Registry *jpush.Registry
+}
+
+func (c *Config) setDefaults() {
+	if c.Initial <= 0 {
+		c.Initial = time.Minute
+	}
+	if c.Max <= 0 {
+		c.Max = time.Hour
+	}
+	if c.Expire <= 0 {
+		c.Expire = 24 * time.Hour
+	}
+	if c.Buffer <= 0 {
+		c.Buffer = 256
+	}
+}
+
+type EventKind int
+
+const (
+	EventSent EventKind = iota + 1
+	EventReceived
+	EventClicked
+	EventReport // report counters changed
+	EventDeviceStatus
+)
+
+type Event struct {
+	Kind           EventKind
+	Id             string // our message id
+	MsgId          string
+	RegistrationId string
+	Platform       jpush.Platform
+}
+
+type Tracker struct {
+	reporter Reporter
+	store    Store
+	config   Config
+	events   chan *Event
+	dropped  int64
+
+	mu     sync.Mutex // serializes read-modify-write on the store
+	pollMu sync.Mutex // one Poll at a time
+	now    func() time.Time
+}
+
+func New(reporter Reporter, store Store, config Config) *Tracker {
+	config.setDefaults()
+	return &Tracker{
+		reporter: reporter,
+		store:    store,
+		config:   config,
+		events:   make(chan *Event, config.Buffer),
+		now:      time.Now,
+	}
+}
+
+// Events streams status changes. When the consumer falls behind and the
+// buffer is full, events are dropped rather than blocking tracking; the
+// stored status stays complete either way.
+func (t *Tracker) Events() <-chan *Event {
+	return t.events
+}
+
+func (t *Tracker) Dropped() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dropped
+}
+
+func (t *Tracker) emit(e *Event) {
+	select {
+	case t.events <- e:
+	default:
+		t.dropped++
+	}
+}
+
+// Track starts following the msg ids JPush returned for our message id.
+// The registration ids, when known, are also checked via MessageStatus.
+func (t *Tracker) Track(id string, msgIds []string, registrationIds []string) error {
+	if id == "" || len(msgIds) == 0 {
+		return errors.New("tracker: id and msg ids are required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, err := t.store.Get(id)
+	if err == ErrNotFound {
+		now := t.now()
+		s = &MessageStatus{
+			Id:       id,
+			Created:  now,
+			NextPoll: now.Add(t.config.Initial),
+			Reports:  make(map[string]*jpush.ReceivedDetailResult),
+			Devices:  make(map[string]*DeviceStatus),
+		}
+	} else if err != nil {
+		return err
+	}
+	s.MsgIds = appendMissing(s.MsgIds, msgIds...)
+	for _, regId := range registrationIds {
+		if s.Devices[regId] == nil {
+			s.Devices[regId] = &DeviceStatus{DeliveryStatus: jpush.MessageStatusUnknown}
+		}
+	}
+	s.Done = false
+	if err := t.store.Save(s); err != nil {
+		return err
+	}
+	for _, msgId := range msgIds {
+		t.emit(&Event{Kind: EventSent, Id: id, MsgId: msgId})
+	}
+	return nil
+}
+
+// TrackOutput tracks the pushes made by Client.PushMessage for in.
+func (t *Tracker) TrackOutput(in *common.PushMessageInput, out *common.PushMessageOutput) error {
+	msgIds := out.MsgIds
+	if len(msgIds) == 0 && out.MsgId != "" {
+		msgIds = []string{out.MsgId}
+	}
+	return t.Track(strconv.FormatInt(in.Id, 10), msgIds, nil)
+}
+
+func (t *Tracker) Status(id string) (*MessageStatus, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.store.Get(id)
+}
+
+// HandleCallback merges a delivery callback; register it with
+// jpush.CallbackHandler.Handle for all event types.
+func (t *Tracker) HandleCallback(e *jpush.CallbackEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e.RegistrationId == "" {
+		return
+	}
+	s, err := t.store.GetByMsgId(e.MsgId)
+	if err != nil {
+		return
+	}
+	d := s.Devices[e.RegistrationId]
+	if d == nil {
+		d = &DeviceStatus{DeliveryStatus: jpush.MessageStatusUnknown}
+		s.Devices[e.RegistrationId] = d
+	}
+	d.Platform = e.Platform
+	kind := EventKind(0)
+	switch e.Type {
+	case jpush.CallbackSent:
+		d.Sent, kind = true, EventSent
+	case jpush.CallbackReceived:
+		d.Sent, d.Received, kind = true, true, EventReceived
+	case jpush.CallbackClicked:
+		d.Sent, d.Received, d.Clicked, kind = true, true, true, EventClicked
+	default:
+		return
+	}
+	s.Done = s.Done || s.delivered()
+	if t.store.Save(s) == nil {
+		t.emit(&Event{Kind: kind, Id: s.Id, MsgId: e.MsgId, RegistrationId: e.RegistrationId, Platform: e.Platform})
+	}
+}
+
+// Run polls due messages every interval until stop is closed.
+func (t *Tracker) Run(interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := t.Poll(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// Poll queries the report API for every message due, batching msg ids
+// MaxReceivedDetailIds per ReceivedDetail call, and schedules the next poll
+// with exponential backoff. The store is locked only to pick the due messages
+// and to merge the results, not across the calls to JPush, so callbacks and
+// Track are not held up by a slow poll.
+func (t *Tracker) Poll() error {
+	t.pollMu.Lock()
+	defer t.pollMu.Unlock()
+
+	now := t.now()
+	due, err := t.due(now)
+	if err != nil || len(due) == 0 {
+		return err
+	}
+
+	var msgIds []string
+	for _, s := range due {
+		msgIds = append(msgIds, s.MsgIds...)
+	}
+	reports := make(map[string]*jpush.ReceivedDetailResult, len(msgIds))
+	var firstErr error
+	for start := 0; start < len(msgIds); start += MaxReceivedDetailIds {
+		end := start + MaxReceivedDetailIds
+		if end > len(msgIds) {
+			end = len(msgIds)
+		}
+		results, err := t.reporter.ReceivedDetail(msgIds[start:end])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, r := range results {
+			reports[string(r.MsgId)] = r
+		}
+	}
+	devices := make(map[string]deviceResults, len(due))
+	for _, s := range due {
+		results, err := t.pollDevices(s)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		devices[s.Id] = results
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, polled := range due {
+		// merge into the stored status, which callbacks may have changed
+		s, err := t.store.Get(polled.Id)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		t.mergeReports(s, reports)
+		if err := t.mergeDevices(s, devices[s.Id]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		s.Attempts++
+		s.LastPoll = now
+		s.NextPoll = now.Add(t.backoff(s.Attempts))
+		s.Done = now.Sub(s.Created) >= t.config.Expire || s.delivered()
+		if err := t.store.Save(s); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// pruner is implemented by stores that can drop done messages.
+type pruner interface {
+	Prune(before time.Time) (int, error)
+}
+
+// due lists the messages to poll at now, after pruning the done messages
+// older than Config.Retain.
+func (t *Tracker) due(now time.Time) ([]*MessageStatus, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.store.(pruner); ok && t.config.Retain > 0 {
+		if _, err := p.Prune(now.Add(-t.config.Retain)); err != nil {
+			return nil, err
+		}
+	}
+	list, err := t.store.List()
+	if err != nil {
+		return nil, err
+	}
+	var due []*MessageStatus
+	for _, s := range list {
+		if !s.Done && !s.NextPoll.After(now) {
+			due = append(due, s)
+		}
+	}
+	return due, nil
+}
+
+func (t *Tracker) mergeReports(s *MessageStatus, reports map[string]*jpush.ReceivedDetailResult) {
+	for _, msgId := range s.MsgIds {
+		r := reports[msgId]
+		if r == nil {
+			continue
+		}
+		if old := s.Reports[msgId]; old == nil || *old != *r {
+			s.Reports[msgId] = r
+			t.emit(&Event{Kind: EventReport, Id: s.Id, MsgId: msgId})
+		}
+	}
+}
+
+// deviceResults holds MessageStatus results by msg id.
+type deviceResults map[string]map[string]*jpush.MessageStatusResult
+
+// mergeDevices updates the delivery status of every device, and returns the
+// first error marking an invalid one in the registry.
+func (t *Tracker) mergeDevices(s *MessageStatus, results deviceResults) error {
+	var firstErr error
+	for regId, d := range s.Devices {
+		status, msgId, ok := bestStatus(s.MsgIds, results, regId)
+		if !ok || d.DeliveryStatus == status {
+			continue
+		}
+		d.DeliveryStatus = status
+		t.emit(&Event{Kind: EventDeviceStatus, Id: s.Id, MsgId: msgId, RegistrationId: regId, Platform: d.Platform})
+		if status == jpush.MessageInvalidRegistrationId && t.config.Registry != nil {
+			if err := t.config.Registry.MarkIneligible(regId); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// statusRank orders the statuses one device gets under the msg ids of a
+// message. A chunked or localized message targets each device with one of
+// them only, and the others report MessageNotTargeted, which must not hide
+// the status of the one that did.
+var statusRank = map[jpush.DeliveryStatus]int{
+	jpush.MessageNotTargeted:           1,
+	jpush.MessageInvalidRegistrationId: 2,
+	jpush.MessageSystemError:           3,
+	jpush.MessageNotDelivered:          4,
+	jpush.MessageDelivered:             5,
+}
+
+// bestStatus returns the highest ranked status reported for regId and the
+// first msg id reporting it.
+func bestStatus(msgIds []string, results deviceResults, regId string) (jpush.DeliveryStatus, string, bool) {
+	var best *jpush.MessageStatusResult
+	var bestMsgId string
+	for _, msgId := range msgIds {
+		r := results[msgId][regId]
+		if r != nil && (best == nil || statusRank[r.Status] > statusRank[best.Status]) {
+			best, bestMsgId = r, msgId
+		}
+	}
+	if best == nil {
+		return 0, "", false
+	}
+	return best.Status, bestMsgId, true
+}
+
+// pollDevices asks MessageStatus about devices still without a final
+// delivery status, one call per msg id. On error it returns the results of
+// the calls made before.
+func (t *Tracker) pollDevices(s *MessageStatus) (deviceResults, error) {
+	var regIds []string
+	for regId, d := range s.Devices {
+		if d.DeliveryStatus != jpush.MessageDelivered && !d.Received {
+			regIds = append(regIds, regId)
+		}
+	}
+	results := make(deviceResults)
+	if len(regIds) == 0 {
+		return results, nil
+	}
+	for _, msgId := range s.MsgIds {
+		result, err := t.reporter.MessageStatus(&jpush.MessageStatusPayload{
+			MsgId:           jpush.MsgId(msgId),
+			RegistrationIds: regIds,
+		})
+		if err != nil {
+			return results, err
+		}
+		results[msgId] = result
+	}
+	return results, nil
+}
+
+func (t *Tracker) backoff(attempts int) time.Duration {
+	d := t.config.Initial
+	for i := 1; i < attempts && d < t.config.Max; i++ {
+		d *= 2
+	}
+	if d > t.config.Max {
+		d = t.config.Max
+	}
+	return d
+}
+
+func appendMissing(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, w := range list {
+			if v == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,267 @@
+package tracker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sustring/push/jpush"
+)
+
+type fakeReporter struct {
+	during func() // called inside every ReceivedDetail
+	calls  [][]string
+	detail map[string]*jpush.ReceivedDetailResult
+	status map[string]*jpush.MessageStatusResult
+	// statusByMsgId, when it has the msg id, answers MessageStatus instead
+	statusByMsgId map[string]map[string]*jpush.MessageStatusResult
+}
+
+func (f *fakeReporter) ReceivedDetail(msgIds []string) ([]*jpush.ReceivedDetailResult, error) {
+	f.calls = append(f.calls, msgIds)
+	if f.during != nil {
+		f.during()
+	}
+	var list []*jpush.ReceivedDetailResult
+	for _, id := range msgIds {
+		if r, ok := f.detail[id]; ok {
+			copied := *r
+			list = append(list, &copied)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeReporter) MessageStatus(payload *jpush.MessageStatusPayload) (map[string]*jpush.MessageStatusResult, error) {
+	if status, ok := f.statusByMsgId[string(payload.MsgId)]; ok {
+		return status, nil
+	}
+	return f.status, nil
+}
+
+func TestTrackerPollAndCallbacks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push-tracker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewFileStore(filepath.Join(dir, "status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reporter := &fakeReporter{
+		detail: map[string]*jpush.ReceivedDetailResult{
+			"1001": {MsgId: "1001", JPushReceived: 3, AndroidPNSSent: 2, IOSAPNSSent: 4, IOSAPNSReceived: 1},
+		},
+		status: map[string]*jpush.MessageStatusResult{"reg-a": {Status: 0}},
+	}
+	now := time.Unix(1600000000, 0)
+	tr := New(reporter, store, Config{Initial: time.Minute, Max: 4 * time.Minute})
+	tr.now = func() time.Time { return now }
+
+	var msgIds []string
+	for i := 0; i < 150; i++ {
+		msgIds = append(msgIds, strconv.Itoa(1001+i))
+	}
+	if err := tr.Track("42", msgIds, []string{"reg-a", "reg-b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.Poll(); err != nil || len(reporter.calls) != 0 {
+		t.Fatalf("polled before due: %v %d", err, len(reporter.calls))
+	}
+	now = now.Add(time.Minute)
+	if err := tr.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reporter.calls) != 2 || len(reporter.calls[0]) != MaxReceivedDetailIds || len(reporter.calls[1]) != 50 {
+		t.Fatalf("expected batches of 100 and 50, got %d calls", len(reporter.calls))
+	}
+
+	tr.HandleCallback(&jpush.CallbackEvent{Type: jpush.CallbackClicked, MsgId: "1001", RegistrationId: "reg-b", Platform: jpush.PlatformIOS})
+
+	// reload from disk to check the state survives a restart
+	store, err = NewFileStore(filepath.Join(dir, "status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.Get("42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Done {
+		t.Fatal("expected polling to stop once every device received the message")
+	}
+	if s.Devices["reg-a"].DeliveryStatus != 0 || !s.Devices["reg-b"].Clicked {
+		t.Fatalf("unexpected devices %+v %+v", s.Devices["reg-a"], s.Devices["reg-b"])
+	}
+	p := s.Platforms()
+	if p[jpush.PlatformAndroid].Received != 3 || p[jpush.PlatformIOS].Sent != 4 || p[jpush.PlatformIOS].Clicked != 1 {
+		t.Fatalf("unexpected platforms %+v %+v", p[jpush.PlatformAndroid], p[jpush.PlatformIOS])
+	}
+
+	kinds := make(map[EventKind]int)
+	for len(tr.Events()) > 0 {
+		kinds[(<-tr.Events()).Kind]++
+	}
+	if kinds[EventSent] != 150 || kinds[EventReport] != 1 || kinds[EventDeviceStatus] != 1 || kinds[EventClicked] != 1 {
+		t.Fatalf("unexpected events %v", kinds)
+	}
+}
+
+func TestTrackerPollUnlocked(t *testing.T) {
+	reporter := &fakeReporter{
+		detail: map[string]*jpush.ReceivedDetailResult{"1001": {MsgId: "1001", JPushReceived: 1}},
+	}
+	now := time.Unix(1600000000, 0)
+	tr := New(reporter, NewMemoryStore(), Config{Initial: time.Minute})
+	tr.now = func() time.Time { return now }
+	if err := tr.Track("42", []string{"1001"}, []string{"reg-a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a callback arriving while JPush is being polled neither waits for the
+	// poll nor is lost when its results are merged
+	reporter.during = func() {
+		tr.HandleCallback(&jpush.CallbackEvent{Type: jpush.CallbackReceived, MsgId: "1001", RegistrationId: "reg-a"})
+		if _, err := tr.Status("42"); err != nil {
+			t.Error(err)
+		}
+	}
+	now = now.Add(time.Minute)
+	done := make(chan error, 1)
+	go func() { done <- tr.Poll() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("poll held the lock across the report call")
+	}
+
+	s, err := tr.Status("42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Devices["reg-a"].Received || s.Reports["1001"] == nil || s.Attempts != 1 || !s.Done {
+		t.Fatalf("unexpected status %+v", s)
+	}
+}
+
+func TestTrackerDeviceStatusAcrossMsgIds(t *testing.T) {
+	reporter := &fakeReporter{
+		statusByMsgId: map[string]map[string]*jpush.MessageStatusResult{
+			"1001": {"reg-a": {Status: jpush.MessageDelivered}},
+			"1002": {"reg-a": {Status: jpush.MessageNotTargeted}},
+		},
+	}
+	now := time.Unix(1600000000, 0)
+	tr := New(reporter, NewMemoryStore(), Config{Initial: time.Minute})
+	tr.now = func() time.Time { return now }
+	if err := tr.Track("42", []string{"1001", "1002"}, []string{"reg-a"}); err != nil {
+		t.Fatal(err)
+	}
+	<-tr.Events()
+	<-tr.Events()
+
+	now = now.Add(time.Minute)
+	if err := tr.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := tr.Status("42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Devices["reg-a"].DeliveryStatus != jpush.MessageDelivered || !s.Done {
+		t.Fatalf("unexpected status %+v, done %v", s.Devices["reg-a"], s.Done)
+	}
+	if e := <-tr.Events(); e.Kind != EventDeviceStatus || e.MsgId != "1001" || len(tr.Events()) != 0 {
+		t.Fatalf("unexpected event %+v, %d more", e, len(tr.Events()))
+	}
+}
+
+func TestTrackerMarksInvalidDevicesIneligible(t *testing.T) {
+	reporter := &fakeReporter{
+		statusByMsgId: map[string]map[string]*jpush.MessageStatusResult{
+			"1001": {
+				"reg-a": {Status: jpush.MessageInvalidRegistrationId},
+				"reg-b": {Status: jpush.MessageNotDelivered},
+			},
+		},
+	}
+	registry := jpush.NewRegistry(jpush.NewMemoryRegistryStore())
+	for _, regId := range []string{"reg-a", "reg-b"} {
+		if err := registry.Seen(regId, jpush.PlatformAndroid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Unix(1600000000, 0)
+	tr := New(reporter, NewMemoryStore(), Config{Initial: time.Minute, Registry: registry})
+	tr.now = func() time.Time { return now }
+	if err := tr.Track("42", []string{"1001"}, []string{"reg-a", "reg-b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := tr.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	for regId, eligible := range map[string]bool{"reg-a": false, "reg-b": true} {
+		e, err := registry.Get(regId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Eligible != eligible {
+			t.Errorf("%s: eligible %v, want %v", regId, e.Eligible, eligible)
+		}
+	}
+}
+
+func TestTrackerPrunesDoneMessages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push-tracker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "status.json")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	for _, s := range []*MessageStatus{
+		{Id: "old-done", MsgIds: []string{"1001"}, Created: now.Add(-2 * time.Hour), Done: true},
+		{Id: "new-done", MsgIds: []string{"1002"}, Created: now.Add(-time.Minute), Done: true},
+		{Id: "old-open", MsgIds: []string{"1003"}, Created: now.Add(-2 * time.Hour), NextPoll: now.Add(time.Hour)},
+	} {
+		if err := store.Save(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tr := New(&fakeReporter{}, store, Config{Retain: time.Hour})
+	tr.now = func() time.Time { return now }
+	if err := tr.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []Store{store, reopened} {
+		if _, err := s.Get("old-done"); err != ErrNotFound {
+			t.Fatalf("old done message kept: %v", err)
+		}
+		if _, err := s.GetByMsgId("1001"); err != ErrNotFound {
+			t.Fatalf("old done msg id kept: %v", err)
+		}
+		if list, err := s.List(); err != nil || len(list) != 2 {
+			t.Fatalf("expected 2 messages left, got %d, %v", len(list), err)
+		}
+	}
+}